@@ -0,0 +1,325 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "time"
+    "sort"
+    "sync"
+    "strconv"
+    "errors"
+    "encoding/csv"
+    "encoding/binary"
+)
+
+// SignalSource provides the analog inputs of an analog to digital converter.
+// Sample returns the value of a channel at time t after the source was
+// attached. Values are in the range -1 to +1 of full scale.
+type SignalSource interface {
+    Sample(channel int, t time.Duration) float64
+}
+
+// SignalFunc is a function that implements the SignalSource interface.
+type SignalFunc func(channel int, t time.Duration) float64
+
+// Sample implements the SignalSource interface.
+func (f SignalFunc) Sample(channel int, t time.Duration) float64 {
+    return f(channel, t)
+}
+
+// SampleSource is a SignalSource that plays back recorded samples. The value
+// of each channel is held from one sample to the next.
+type SampleSource struct {
+    times []time.Duration   // Sample times in ascending order
+    values [][]float64      // Channel values at each sample time
+}
+
+// Sample implements the SignalSource interface. 0 is returned before the first
+// sample and for channels that are not recorded.
+func (s *SampleSource) Sample(channel int, t time.Duration) float64 {
+    i := sort.Search(len(s.times), func(i int) bool {
+        return s.times[i] > t
+    }) - 1
+    if i < 0 || channel < 0 || channel >= len(s.values[i]) {
+        return 0
+    }
+    return s.values[i][channel]
+}
+
+// NewCSVSource reads samples from CSV records. The first field of each record
+// is the sample time in seconds and the remaining fields are the values of
+// channels 0, 1, 2 and so on. Records must be in time order. A header record
+// and lines beginning with # are ignored.
+func NewCSVSource(r io.Reader) (*SampleSource, error) {
+    c := csv.NewReader(r)
+    c.Comment = '#'
+    c.FieldsPerRecord = -1
+    c.TrimLeadingSpace = true
+
+    s := &SampleSource{}
+    for line := 1; ; line++ {
+        record, err := c.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        fields := make([]float64, len(record))
+        for i, field := range record {
+            fields[i], err = strconv.ParseFloat(field, 64)
+            if err != nil {
+                break
+            }
+        }
+        if err != nil {
+            if line == 1 {
+                // Header
+                continue
+            }
+            return nil, fmt.Errorf("record %d: %v", line, err)
+        }
+        if len(fields) < 2 {
+            return nil, fmt.Errorf("record %d: no channel values", line)
+        }
+
+        t := time.Duration(fields[0]*float64(time.Second))
+        if n := len(s.times); n > 0 && t < s.times[n - 1] {
+            return nil, fmt.Errorf("record %d: time out of order", line)
+        }
+        s.times = append(s.times, t)
+        s.values = append(s.values, fields[1:])
+    }
+    return s, nil
+}
+
+// NewWAVSource reads samples from a PCM WAV file with 8 or 16-bit samples.
+// Each channel of the file is an input channel.
+func NewWAVSource(r io.Reader) (*SampleSource, error) {
+    var riff struct {
+        ID [4]byte
+        Size uint32
+        Format [4]byte
+    }
+    if err := binary.Read(r, binary.LittleEndian, &riff); err != nil {
+        return nil, err
+    }
+    if string(riff.ID[:]) != "RIFF" || string(riff.Format[:]) != "WAVE" {
+        return nil, errors.New("not a WAV file")
+    }
+
+    var format struct {
+        AudioFormat uint16
+        Channels uint16
+        SampleRate uint32
+        ByteRate uint32
+        BlockAlign uint16
+        BitsPerSample uint16
+    }
+    haveFormat := false
+    for {
+        var chunk struct {
+            ID [4]byte
+            Size uint32
+        }
+        if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+            return nil, err
+        }
+        data := make([]byte, chunk.Size + chunk.Size&1)   // Chunks are word aligned
+        if _, err := io.ReadFull(r, data); err != nil {
+            return nil, err
+        }
+        data = data[:chunk.Size]
+
+        switch string(chunk.ID[:]) {
+        case "fmt ":
+            if len(data) < 16 {
+                return nil, errors.New("invalid WAV format chunk")
+            }
+            format.AudioFormat = binary.LittleEndian.Uint16(data[0:])
+            format.Channels = binary.LittleEndian.Uint16(data[2:])
+            format.SampleRate = binary.LittleEndian.Uint32(data[4:])
+            format.BlockAlign = binary.LittleEndian.Uint16(data[12:])
+            format.BitsPerSample = binary.LittleEndian.Uint16(data[14:])
+            if format.AudioFormat != 1 {
+                return nil, errors.New("WAV file not PCM")
+            }
+            if format.BitsPerSample != 8 && format.BitsPerSample != 16 {
+                return nil, fmt.Errorf("unsupported WAV sample size: %d", format.BitsPerSample)
+            }
+            if format.Channels == 0 || format.SampleRate == 0 {
+                return nil, errors.New("invalid WAV format chunk")
+            }
+            haveFormat = true
+        case "data":
+            if !haveFormat {
+                return nil, errors.New("WAV data before format")
+            }
+            return wavSamples(data, int(format.Channels), int(format.BitsPerSample),
+                int(format.BlockAlign), int(format.SampleRate)), nil
+        }
+    }
+}
+
+// wavSamples converts WAV sample frames to a SampleSource.
+func wavSamples(data []byte, channels, bits, align, rate int) *SampleSource {
+    if align < channels*bits/8 {
+        align = channels*bits/8
+    }
+    frames := len(data)/align
+    s := &SampleSource{
+        times: make([]time.Duration, frames),
+        values: make([][]float64, frames),
+    }
+    for i := 0; i < frames; i++ {
+        s.times[i] = time.Duration(i)*time.Second/time.Duration(rate)
+        frame := data[i*align:]
+        values := make([]float64, channels)
+        for ch := range values {
+            if bits == 8 {
+                values[ch] = float64(int(frame[ch]) - 128)/128
+            } else {
+                values[ch] = float64(int16(binary.LittleEndian.Uint16(frame[2*ch:])))/32768
+            }
+        }
+        s.values[i] = values
+    }
+    return s
+}
+
+// Sample is an analog output sample. Time is the time after the sink was
+// attached and Value is in the range -1 to +1 of full scale.
+type Sample struct {
+    Time time.Duration
+    Channel int
+    Value float64
+}
+
+// SampleSink receives the analog outputs of a digital to analog converter.
+type SampleSink interface {
+    WriteSample(s Sample) error
+}
+
+// SampleRecorder is a SampleSink that records samples in memory.
+type SampleRecorder struct {
+    mu sync.Mutex
+    samples []Sample
+}
+
+// WriteSample implements the SampleSink interface.
+func (r *SampleRecorder) WriteSample(s Sample) error {
+    r.mu.Lock()
+    r.samples = append(r.samples, s)
+    r.mu.Unlock()
+    return nil
+}
+
+// Samples returns the samples recorded so far.
+func (r *SampleRecorder) Samples() []Sample {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return append([]Sample(nil), r.samples...)
+}
+
+// CSVSink is a SampleSink that writes samples as CSV records of time in
+// seconds, channel and value.
+type CSVSink struct {
+    w *csv.Writer
+}
+
+// NewCSVSink returns a sink that writes samples to w.
+func NewCSVSink(w io.Writer) *CSVSink {
+    return &CSVSink{w: csv.NewWriter(w)}
+}
+
+// WriteSample implements the SampleSink interface.
+func (c *CSVSink) WriteSample(s Sample) error {
+    c.w.Write([]string{
+        strconv.FormatFloat(s.Time.Seconds(), 'f', 6, 64),
+        strconv.Itoa(s.Channel),
+        strconv.FormatFloat(s.Value, 'g', -1, 64),
+    })
+    c.w.Flush()
+    return c.w.Error()
+}
+
+// AnalogInput is analog to digital converter media. Bits is the converter
+// resolution, from 2 to 16 bits; if zero, 12 bits is used.
+type AnalogInput struct {
+    Source SignalSource
+    Bits int
+}
+
+// AnalogOutput is digital to analog converter media. Bits is the converter
+// resolution, from 2 to 16 bits; if zero, 12 bits is used.
+type AnalogOutput struct {
+    Sink SampleSink
+    Bits int
+}
+
+// analogBits returns the resolution to use for the requested bits.
+func analogBits(bits int) uint {
+    switch {
+    case bits == 0:
+        return 12
+    case bits < 2:
+        return 2
+    case bits > 16:
+        return 16
+    }
+    return uint(bits)
+}
+
+// encodeAnalog converts v to a two's complement value of the specified number
+// of bits, sign extended to 16 bits.
+func encodeAnalog(v float64, bits uint) uint16 {
+    max := float64(int(1) << (bits - 1) - 1)
+    switch {
+    case v > 1:
+        v = 1
+    case v < -1:
+        v = -1
+    }
+    var code int
+    if v < 0 {
+        code = int(v*max - 0.5)
+    } else {
+        code = int(v*max + 0.5)
+    }
+    return uint16(int16(code))
+}
+
+// decodeAnalog converts the low order bits of data in two's complement to a
+// value in the range -1 to +1.
+func decodeAnalog(data uint16, bits uint) float64 {
+    shift := 16 - bits
+    code := int16(data << shift) >> shift
+    v := float64(code)/float64(int(1) << (bits - 1) - 1)
+    if v < -1 {
+        v = -1
+    }
+    return v
+}
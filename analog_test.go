@@ -0,0 +1,121 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "encoding/binary"
+    "strings"
+    "time"
+
+    "testing"
+)
+
+func TestCSVSource(t *testing.T) {
+    s, err := NewCSVSource(strings.NewReader("time,ch0,ch1\n0,0.5,-1\n# comment\n0.5,0.25\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        channel int
+        t time.Duration
+        want float64
+    }{
+        {0, 0, 0.5},
+        {1, time.Millisecond*499, -1},
+        {0, time.Second, 0.25},
+        {1, time.Second, 0},
+    }
+    for _, test := range tests {
+        have := s.Sample(test.channel, test.t)
+        if have != test.want {
+            t.Errorf("channel %d at %v: have: %g, want: %g", test.channel, test.t, have, test.want)
+        }
+    }
+
+    _, err = NewCSVSource(strings.NewReader("1,0\n0,0\n"))
+    if err == nil {
+        t.Error("have: nil, want: err")
+    }
+}
+
+func TestWAVSource(t *testing.T) {
+    samples := []int16{16384, -32768, 0, 32767}
+    var b bytes.Buffer
+    b.WriteString("RIFF")
+    binary.Write(&b, binary.LittleEndian, uint32(36 + 2*len(samples)))
+    b.WriteString("WAVEfmt ")
+    binary.Write(&b, binary.LittleEndian, []uint32{16, 0x00020001, 1000, 4000, 0x00100004})
+    b.WriteString("data")
+    binary.Write(&b, binary.LittleEndian, uint32(2*len(samples)))
+    binary.Write(&b, binary.LittleEndian, samples)
+
+    s, err := NewWAVSource(&b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if have := s.Sample(0, 0); have != 0.5 {
+        t.Errorf("have: %g, want: 0.5", have)
+    }
+    if have := s.Sample(1, time.Millisecond); have != 32767.0/32768 {
+        t.Errorf("have: %g, want: %g", have, 32767.0/32768)
+    }
+}
+
+func TestAnalogConverters(t *testing.T) {
+    n := NewNova()
+    err := n.Attach(DevADCV, &AnalogInput{
+        Source: SignalFunc(func(channel int, t time.Duration) float64 {
+            return float64(channel)/-10
+        }),
+        Bits: 10,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    var r SampleRecorder
+    err = n.Attach(DevDACV, &r)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    adc := n.devices[DevADCV]
+    adc.write(ioDOA, ioS, 5)
+    waitDone(t, adc)
+    data := adc.read(ioDIA, ioC)
+    if want := uint16(0177400); data != want {    // Half of negative full scale
+        t.Errorf("have: %06o, want: %06o", data, want)
+    }
+
+    dac := n.devices[DevDACV]
+    dac.write(ioDOB, 0, 3)
+    dac.write(ioDOA, 0, 04000)     // Most negative 12-bit value
+    dac.write(ioDOA, 0, 03777)     // Most positive 12-bit value
+    samples := r.Samples()
+    if len(samples) != 2 {
+        t.Fatalf("have: %d samples, want: 2", len(samples))
+    }
+    if samples[0].Channel != 3 || samples[0].Value != -1 || samples[1].Value != 1 {
+        t.Errorf("have: %v, want: channel 3, values -1 and 1", samples)
+    }
+}
@@ -0,0 +1,336 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+)
+
+// File attach modes for AttachFile.
+const (
+    AttachDefault = iota    // Read input, create output, update tapes
+    AttachReadOnly          // Open an existing file read only
+    AttachAppend            // Write after the existing contents of the file
+    AttachCreate            // Create the file, truncating an existing file
+    AttachNew               // Create the file, which must not already exist
+)
+
+var attachModes = [...]string{
+    AttachDefault: "default",
+    AttachReadOnly: "read only",
+    AttachAppend: "append",
+    AttachCreate: "create",
+    AttachNew: "new",
+}
+
+// Bytes read from a card deck file to detect its format.
+const kDeckCheck = 16*CardColumns*2
+
+// How a device uses an attached file.
+const (
+    fileRead = iota + 1
+    fileWrite
+    fileTape
+)
+
+// Attachment describes the media attached to a device.
+type Attachment struct {
+    Code int           // Device code
+    Media string       // Type of the media
+    Path string        // File name if attached by AttachFile
+    Mode int           // Attach mode if attached by AttachFile
+    Format string      // Detected image format, if any
+}
+
+// String returns a one line description of the attachment.
+func (a Attachment) String() string {
+    return deviceName(uint16(a.Code)) + ": " + a.describe()
+}
+
+// describe returns a description of the media.
+func (a Attachment) describe() string {
+    if a.Path == "" {
+        return a.Media
+    }
+    s := fmt.Sprintf("%s (%s", a.Path, attachModes[a.Mode])
+    if a.Format != "" {
+        s += ", " + a.Format
+    }
+    return s + ")"
+}
+
+// attachment is the record of the media attached to a device.
+type attachment struct {
+    Attachment
+    f *os.File      // File opened by AttachFile
+}
+
+// AttachFile opens the file path and attaches it to a device, replacing any
+// media already attached. The file is closed when the device is detached,
+// when other media is attached, or when the processor is closed.
+//
+// The mode controls how the file is opened. By default, the file of an input
+// device such as DevPTR must exist and is read, the file of an output device
+// such as DevPTP is created or truncated, and the tape image of DevCAS is
+// opened for update; a tape image that cannot be written is write locked.
+// AttachReadOnly opens an existing file read only, so that a tape image is
+// write locked. AttachAppend writes after the contents of the file, creating
+// it if necessary; a tape is positioned at the end of its recorded data.
+// AttachCreate creates the file, truncating any existing contents, and
+// AttachNew creates the file, which must not already exist.
+//
+// The format of a tape image is checked before it is attached. The card
+// reader reads a binary deck, in the format punched by DevCDP, if the file
+// holds whole card images that are not plain text, and otherwise reads a text
+// deck. An error is returned if the file cannot be opened in the requested
+// mode, if the device cannot be attached to a file, or if the image is not in
+// the format used by the device.
+func (n *Nova) AttachFile(code int, path string, mode int) error {
+    num := uint16(code)&077
+    dev := n.devices[num]
+    if dev == nil {
+        return fmt.Errorf("%s: device not found", deviceName(num))
+    }
+    if mode < AttachDefault || mode > AttachNew {
+        return fmt.Errorf("%s: invalid attach mode: %d", deviceName(num), mode)
+    }
+
+    access := fileAccess(dev)
+    f, flag, err := openFile(path, access, mode)
+    if err != nil {
+        return fmt.Errorf("%s: %v", deviceName(num), err)
+    }
+    a := &attachment{
+        Attachment: Attachment{
+            Code: int(num),
+            Media: "file",
+            Path: path,
+            Mode: mode,
+        },
+        f: f,
+    }
+
+    var media interface{} = f
+    switch dev.(type) {
+    case *cassette:
+        a.Format = "SIMH tape image"
+        locked := flag&(os.O_WRONLY|os.O_RDWR) == 0
+        media, err = openTape(f, mode == AttachAppend, locked)
+    case *cardReader:
+        media, a.Format, err = openDeck(f)
+    case *cardPunch:
+        a.Format = "binary card deck"
+    }
+    if err != nil {
+        f.Close()
+        return fmt.Errorf("%s: %s: %v", deviceName(num), path, err)
+    }
+    if err := n.attach(num, media); err != nil {
+        f.Close()
+        return err
+    }
+    n.record(num, a)
+    return nil
+}
+
+// fileAccess returns how device d uses an attached file, or 0 if a file cannot
+// be attached to d.
+func fileAccess(d driver) int {
+    switch d.(type) {
+    case *cassette:
+        return fileTape
+    case inputDriver, *cardReader:
+        return fileRead
+    case outputDriver, *cardPunch:
+        return fileWrite
+    }
+    return 0
+}
+
+// openFile opens path for the access required by a device in the attach mode
+// and returns the file and the flags it was opened with.
+func openFile(path string, access, mode int) (*os.File, int, error) {
+    var flag int
+    switch access {
+    case fileRead:
+        if mode != AttachDefault && mode != AttachReadOnly {
+            return nil, 0, errors.New("input device cannot write file")
+        }
+        flag = os.O_RDONLY
+    case fileWrite:
+        flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+        if mode == AttachReadOnly {
+            return nil, 0, errors.New("output device cannot read file")
+        }
+    case fileTape:
+        flag = os.O_RDWR
+    default:
+        return nil, 0, errors.New("cannot attach file")
+    }
+
+    switch mode {
+    case AttachReadOnly:
+        flag = os.O_RDONLY
+    case AttachAppend:
+        flag |= os.O_CREATE
+        flag &^= os.O_TRUNC
+        if access == fileWrite {
+            flag |= os.O_APPEND
+        }
+    case AttachCreate:
+        flag |= os.O_CREATE | os.O_TRUNC
+    case AttachNew:
+        flag |= os.O_CREATE | os.O_EXCL
+        flag &^= os.O_TRUNC
+    }
+
+    f, err := os.OpenFile(path, flag, 0666)
+    if access == fileTape && mode == AttachDefault && os.IsPermission(err) {
+        // Write locked
+        flag = os.O_RDONLY
+        f, err = os.OpenFile(path, flag, 0)
+    }
+    return f, flag, err
+}
+
+// openTape checks that f holds a tape image and returns the image, positioned
+// at the load point or, if end is set, after the recorded data. A locked tape
+// cannot be written.
+func openTape(f *os.File, end, locked bool) (*TapeImage, error) {
+    if _, err := ReadTapeImage(f); err != nil {
+        return nil, fmt.Errorf("not a tape image: %v", err)
+    }
+    if _, err := f.Seek(0, io.SeekStart); err != nil {
+        return nil, err
+    }
+    t := NewTapeImage(f)
+    t.SetWriteLocked(locked)
+    if end {
+        for {
+            _, err := t.ReadBlock()
+            if err == io.EOF {
+                break
+            }
+            if err != nil && err != ErrFileMark {
+                return nil, err
+            }
+        }
+    }
+    return t, nil
+}
+
+// openDeck returns the card deck held in f and its format. The deck is read as
+// column images if the file holds whole cards, with no punches outside the 12
+// rows, and is not plain text; otherwise it is read as text.
+func openDeck(f *os.File) (CardDeck, string, error) {
+    fi, err := f.Stat()
+    if err != nil {
+        return nil, "", err
+    }
+    size := fi.Size()
+    binary := size > 0 && size%(CardColumns*2) == 0
+    if binary {
+        // Check the first few cards
+        if size > kDeckCheck {
+            size = kDeckCheck
+        }
+        b := make([]byte, size)
+        if _, err := io.ReadFull(f, b); err != nil {
+            return nil, "", err
+        }
+        if _, err := f.Seek(0, io.SeekStart); err != nil {
+            return nil, "", err
+        }
+        text := true
+        for i, c := range b {
+            if i%2 == 1 && c&^(kColumnMask >> 8) != 0 {
+                binary = false
+                break
+            }
+            if (c < ' ' || c > '~') && c != '\t' && c != '\r' && c != '\n' {
+                text = false
+            }
+        }
+        binary = binary && !text
+    }
+    if binary {
+        return NewBinaryDeck(f), "binary card deck", nil
+    }
+    return NewTextDeck(f, nil), "text card deck", nil
+}
+
+// record records the media attached to device num, or that the device has
+// been detached if a is nil. A file attached previously is closed.
+func (n *Nova) record(num uint16, a *attachment) {
+    n.mu.Lock()
+    old := n.attached[num]
+    if a == nil {
+        delete(n.attached, num)
+    } else {
+        n.attached[num] = a
+    }
+    n.mu.Unlock()
+    if old != nil && old.f != nil {
+        old.f.Close()
+    }
+}
+
+// Attachments returns the media attached to devices with Attach or
+// AttachFile, in device code order.
+func (n *Nova) Attachments() []Attachment {
+    n.mu.Lock()
+    list := make([]Attachment, 0, len(n.attached))
+    for _, a := range n.attached {
+        list = append(list, a.Attachment)
+    }
+    n.mu.Unlock()
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].Code < list[j].Code
+    })
+    return list
+}
+
+// Close detaches the files attached with AttachFile and closes them. The first
+// error encountered is returned.
+func (n *Nova) Close() error {
+    var codes []int
+    n.mu.Lock()
+    for num, a := range n.attached {
+        if a.f != nil {
+            codes = append(codes, int(num))
+        }
+    }
+    n.mu.Unlock()
+
+    var first error
+    for _, code := range codes {
+        if err := n.Detach(code); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
@@ -0,0 +1,138 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "testing"
+)
+
+func TestAttachFile(t *testing.T) {
+    dir := t.TempDir()
+    n := NewNova()
+
+    // Punch to a new file, then append to it
+    ptp := filepath.Join(dir, "ptp.bin")
+    d := n.devices[DevPTP]
+    for _, mode := range []int{AttachNew, AttachAppend} {
+        if err := n.AttachFile(DevPTP, ptp, mode); err != nil {
+            t.Fatal(err)
+        }
+        d.write(ioDOA, ioS, 'A')
+        waitDone(t, d)
+    }
+    if err := n.AttachFile(DevPTP, ptp, AttachNew); err == nil {
+        t.Error("new: have: nil, want: error")
+    }
+    if err := n.AttachFile(DevPTR, ptp, AttachCreate); err == nil {
+        t.Error("create input: have: nil, want: error")
+    }
+
+    // Read back the punched tape
+    if err := n.AttachFile(DevPTR, ptp, AttachReadOnly); err != nil {
+        t.Fatal(err)
+    }
+    list := n.Attachments()
+    if len(list) != 2 || list[0].Code != DevPTR || list[1].Code != DevPTP {
+        t.Fatalf("have: %v, want: PTR and PTP", list)
+    }
+    if s := list[0].String(); s != "PTR: "+ptp+" (read only)" {
+        t.Errorf("have: %q", s)
+    }
+    if err := n.Close(); err != nil {
+        t.Fatal(err)
+    }
+    if list := n.Attachments(); len(list) != 0 {
+        t.Errorf("have: %v, want: none", list)
+    }
+    b, err := ioutil.ReadFile(ptp)
+    if err != nil || string(b) != "AA" {
+        t.Errorf("have: %q %v, want: %q", b, err, "AA")
+    }
+
+    // Tape images are checked and may be write locked
+    if err := n.AttachFile(DevCAS, ptp, AttachDefault); err == nil || !strings.Contains(err.Error(), "not a tape image") {
+        t.Errorf("have: %v, want: not a tape image", err)
+    }
+    tap := filepath.Join(dir, "cas.tap")
+    if err := ioutil.WriteFile(tap, nil, 0666); err != nil {
+        t.Fatal(err)
+    }
+    if err := n.AttachFile(DevCAS, tap, AttachReadOnly); err != nil {
+        t.Fatal(err)
+    }
+    c := n.devices[DevCAS]
+    c.write(ioDOA, ioS, casWrite)
+    waitDone(t, c)
+    if status := c.read(ioDIA, ioC); status&casWriteLock == 0 {
+        t.Errorf("status: have: %06o, want: %06o", status, casWriteLock)
+    }
+    if s := n.Attachments()[0].String(); s != "CAS: "+tap+" (read only, SIMH tape image)" {
+        t.Errorf("have: %q", s)
+    }
+}
+
+func TestOpenDeck(t *testing.T) {
+    var bin bytes.Buffer
+    NewBinaryPunch(&bin).PunchCard([]uint16{Row12|Row1, Row0|Row9})
+    tests := []struct{
+        name string
+        data []byte
+        format string
+        col uint16
+    }{
+        {"binary", bin.Bytes(), "binary card deck", Row12|Row1},
+        {"blank", make([]byte, CardColumns*2), "binary card deck", 0},
+        {"text", []byte("A\n"), "text card deck", Row12|Row1},
+        {"lines", bytes.Repeat([]byte("\n"), CardColumns*2), "text card deck", 0},
+    }
+
+    dir := t.TempDir()
+    for _, test := range tests {
+        path := filepath.Join(dir, test.name)
+        if err := ioutil.WriteFile(path, test.data, 0666); err != nil {
+            t.Fatal(err)
+        }
+        f, err := os.Open(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        deck, format, err := openDeck(f)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if format != test.format {
+            t.Errorf("%s: have: %s, want: %s", test.name, format, test.format)
+        }
+        card, err := deck.ReadCard()
+        if err != nil || card[0] != test.col {
+            t.Errorf("%s: have: %04o %v, want: %04o", test.name, card, err, test.col)
+        }
+        f.Close()
+    }
+}
@@ -0,0 +1,170 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "time"
+    "io"
+    "fmt"
+)
+
+// Card punch status bits returned by DIB.
+const (
+    cdpPunchCheck   = 0000001   // Card could not be punched
+    cdpNotReady     = 0000020   // No media attached
+)
+
+// cardPunch emulates a card punch that is punched column by column. DOA loads
+// the 12-bit Hollerith column image and setting Busy punches it in the next
+// column. Done is set, and an interrupt is requested, when the column has been
+// punched. The card is stacked when all 80 columns have been punched or when
+// the program pulses the punch; unpunched columns are left blank. DIB returns
+// the punch status. A card that cannot be punched is a device error; it sets
+// the punch check status unless the error policy is ErrorIgnore.
+type cardPunch struct {
+    controller
+    punch CardPunch
+    card []uint16   // Card being punched
+    status uint16   // Punch status
+    period time.Duration    // Column time
+}
+
+// newCardPunch creates a card punch that punches rate cards per minute.
+func newCardPunch(n *Nova, num, pri uint16, rate float32) *cardPunch {
+    d := &cardPunch{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        // One period per column
+        period: time.Duration(float32(time.Minute)/(rate*CardColumns)),
+    }
+    go d.device()
+    return d
+}
+
+func (d *cardPunch) device() {
+    t := time.NewTimer(time.Second)
+    t.Stop()
+    expired := true
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                if !t.Stop() && !expired {
+                    <-t.C
+                }
+                expired = true
+                d.card = nil
+                d.status = 0
+                d.idle()
+            case ioDOA:
+                d.data = msg.data&kColumnMask
+            case ioDIB:
+                msg.data = d.status
+            case ioNIO, ioDIA, ioDOB, ioDIC, ioDOC:
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            if msg.typ != ioSKP {
+                switch msg.flags {
+                case ioS:
+                    // Punch column; delay until column time has elapsed
+                    if !t.Stop() && !expired {
+                        <-t.C
+                    }
+                    t.Reset(d.period)
+                    expired = false
+                case ioP:
+                    d.stack()
+                }
+                d.flags(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-t.C:
+            expired = true
+            d.status = 0
+            if d.punch == nil {
+                d.status = cdpNotReady
+            } else {
+                d.card = append(d.card, d.data)
+                if len(d.card) == CardColumns {
+                    d.stack()
+                }
+            }
+            d.complete()
+        }
+    }
+}
+
+// stack punches the card and moves it to the stacker.
+func (d *cardPunch) stack() {
+    if d.card == nil || d.punch == nil {
+        return
+    }
+    card := make([]uint16, CardColumns)
+    copy(card, d.card)
+    d.card = nil
+    if err := d.punch.PunchCard(card); err != nil && d.fail(err) != ErrorIgnore {
+        d.status |= cdpPunchCheck
+    }
+}
+
+// mediaErrors reports that the punch media can fail.
+func (d *cardPunch) mediaErrors() {}
+
+// setRate sets the column time.
+func (d *cardPunch) setRate(period time.Duration) {
+    d.sync(func() {
+        d.period = period
+    })
+}
+
+// attachMedia attaches a CardPunch to the punch. Any other io.Writer receives
+// column images in the format read by BinaryDeck. A nil media detaches the
+// punch. A partly punched card is stacked to the previous media before it is
+// replaced.
+func (d *cardPunch) attachMedia(media interface{}) error {
+    var punch CardPunch
+    switch m := media.(type) {
+    case nil:
+    case CardPunch:
+        punch = m
+    case io.Writer:
+        punch = NewBinaryPunch(m)
+    default:
+        return fmt.Errorf("%s: need CardPunch or io.Writer media", deviceName(d.num))
+    }
+    d.sync(func() {
+        d.stack()
+        d.punch = punch
+    })
+    return nil
+}
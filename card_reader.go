@@ -0,0 +1,228 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "time"
+    "io"
+    "fmt"
+)
+
+// Card reader status bits returned by DIB.
+const (
+    cdrDataLate     = 0000001   // Column overwritten before DIA
+    cdrEndOfCard    = 0000002   // Card has passed the read station
+    cdrHopperEmpty  = 0000004   // No more cards in the deck
+    cdrReadCheck    = 0000010   // Card could not be read
+    cdrNotReady     = 0000020   // No deck attached
+)
+
+// cardReader emulates a card reader that is read column by column. Setting
+// Busy feeds a card past the read station. Done is set, and an interrupt is
+// requested, each time a column is latched into the data buffer, when the card
+// has passed the read station, and when a card cannot be fed. Clearing Done
+// does not stop a card in motion. DIA returns the 12-bit Hollerith column
+// image and DIB returns the reader status. A card that cannot be read from
+// the deck is a device error; it sets the read check status unless the error
+// policy is ErrorIgnore, when a blank card is fed instead.
+type cardReader struct {
+    controller
+    deck CardDeck
+    card []uint16   // Card in motion
+    col int         // Next column
+    buf uint16      // Latched column
+    latched bool    // buf has not been read
+    status uint16   // Reader status
+    busy bool
+    done bool
+    period time.Duration    // Column time
+}
+
+// newCardReader creates a card reader that reads rate cards per minute.
+func newCardReader(n *Nova, num, pri uint16, rate float32) *cardReader {
+    d := &cardReader{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        // One period per column plus one for the end of card
+        period: time.Duration(float32(time.Minute)/(rate*(CardColumns + 1))),
+    }
+    go d.device()
+    return d
+}
+
+func (d *cardReader) device() {
+    ticker := time.NewTicker(time.Second)
+    ticker.Stop()
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                stopTicker(ticker)
+                d.busy = false
+                d.card = nil
+                d.latched = false
+                d.status = 0
+                d.clearDone()
+            case ioDIA:
+                msg.data = d.buf
+                d.latched = false
+                d.flags(msg, ticker)
+            case ioDIB:
+                msg.data = d.status
+                d.flags(msg, ticker)
+            case ioNIO, ioDOA, ioDOB, ioDIC, ioDOC:
+                d.flags(msg, ticker)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-ticker.C:
+            if d.col < len(d.card) {
+                // Latch next column
+                if d.latched {
+                    d.status |= cdrDataLate
+                }
+                d.buf = d.card[d.col]
+                d.col++
+                d.latched = true
+            } else {
+                // Card has left the read station
+                stopTicker(ticker)
+                d.busy = false
+                d.card = nil
+                d.status |= cdrEndOfCard
+            }
+            d.setDone()
+        }
+    }
+}
+
+// flags sets the reader state from the message flags.
+func (d *cardReader) flags(msg devmsg, ticker *time.Ticker) {
+    switch msg.flags {
+    case ioS:
+        d.clearDone()
+        if !d.busy {
+            d.feed(ticker)
+        }
+    case ioC:
+        d.clearDone()
+    }
+}
+
+// feed reads the next card from the deck and sets it in motion.
+func (d *cardReader) feed(ticker *time.Ticker) {
+    d.status = 0
+    d.latched = false
+    if d.deck == nil {
+        d.status = cdrNotReady
+        d.setDone()
+        return
+    }
+    card, err := d.deck.ReadCard()
+    if err == io.EOF {
+        d.status = cdrHopperEmpty
+        d.setDone()
+        return
+    }
+    if err != nil {
+        if d.fail(err) != ErrorIgnore {
+            d.status = cdrReadCheck
+            d.setDone()
+            return
+        }
+        // Feed a blank card in place of the card that could not be read
+        card = make([]uint16, CardColumns)
+    }
+    d.card = card
+    d.col = 0
+    d.busy = true
+    if d.period > 0 {
+        ticker.Reset(d.period)
+    } else {
+        // Unthrottled; tickers need a positive period
+        ticker.Reset(time.Nanosecond)
+    }
+}
+
+// skip returns skip condition specified by message flags.
+func (d *cardReader) skip(msg devmsg) uint16 {
+    return skipFlags(msg, d.busy, d.done)
+}
+
+// snapshot returns the Busy and Done flags and the latched column.
+func (d *cardReader) snapshot() (busy, done bool, data uint16) {
+    d.sync(func() {
+        busy, done, data = d.busy, d.done, d.buf
+    })
+    return busy, done, data
+}
+
+func (d *cardReader) setDone() {
+    d.done = true
+    d.n.setInt(d.num)
+}
+
+func (d *cardReader) clearDone() {
+    d.done = false
+    d.n.clearInt(d.num)
+}
+
+// mediaErrors reports that the reader media can fail.
+func (d *cardReader) mediaErrors() {}
+
+// setRate sets the column time.
+func (d *cardReader) setRate(period time.Duration) {
+    d.sync(func() {
+        d.period = period
+    })
+}
+
+// attachMedia attaches a CardDeck to the reader. Any other io.Reader is read
+// as a text deck using the Hollerith029 translation table. A nil media
+// detaches the deck.
+func (d *cardReader) attachMedia(media interface{}) error {
+    var deck CardDeck
+    switch m := media.(type) {
+    case nil:
+    case CardDeck:
+        deck = m
+    case io.Reader:
+        deck = NewTextDeck(m, nil)
+    default:
+        return fmt.Errorf("%s: need CardDeck or io.Reader media", deviceName(d.num))
+    }
+    d.sync(func() {
+        d.deck = deck
+    })
+    return nil
+}
@@ -0,0 +1,278 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "bufio"
+    "strings"
+    "errors"
+    "fmt"
+    "unicode"
+)
+
+// Number of columns on a punched card.
+const CardColumns = 80
+
+// Hollerith row punches. A card column is represented by a 12-bit value with
+// row 12 in the most significant bit and row 9 in the least significant bit.
+const (
+    Row12 uint16 = 04000
+    Row11 uint16 = 02000
+    Row0  uint16 = 01000
+    Row1  uint16 = 00400
+    Row2  uint16 = 00200
+    Row3  uint16 = 00100
+    Row4  uint16 = 00040
+    Row5  uint16 = 00020
+    Row6  uint16 = 00010
+    Row7  uint16 = 00004
+    Row8  uint16 = 00002
+    Row9  uint16 = 00001
+
+    kColumnMask = 07777
+)
+
+// HollerithTable translates characters to card column punches.
+type HollerithTable map[rune]uint16
+
+// Hollerith029 is the IBM 029 keypunch character set. Lower case letters are
+// punched as their upper case equivalents.
+var Hollerith029 = HollerithTable{
+    ' ': 0,
+    '&': Row12,
+    '-': Row11,
+    '0': Row0,
+    '1': Row1,
+    '2': Row2,
+    '3': Row3,
+    '4': Row4,
+    '5': Row5,
+    '6': Row6,
+    '7': Row7,
+    '8': Row8,
+    '9': Row9,
+    'A': Row12|Row1,
+    'B': Row12|Row2,
+    'C': Row12|Row3,
+    'D': Row12|Row4,
+    'E': Row12|Row5,
+    'F': Row12|Row6,
+    'G': Row12|Row7,
+    'H': Row12|Row8,
+    'I': Row12|Row9,
+    'J': Row11|Row1,
+    'K': Row11|Row2,
+    'L': Row11|Row3,
+    'M': Row11|Row4,
+    'N': Row11|Row5,
+    'O': Row11|Row6,
+    'P': Row11|Row7,
+    'Q': Row11|Row8,
+    'R': Row11|Row9,
+    '/': Row0|Row1,
+    'S': Row0|Row2,
+    'T': Row0|Row3,
+    'U': Row0|Row4,
+    'V': Row0|Row5,
+    'W': Row0|Row6,
+    'X': Row0|Row7,
+    'Y': Row0|Row8,
+    'Z': Row0|Row9,
+    ':': Row2|Row8,
+    '#': Row3|Row8,
+    '@': Row4|Row8,
+    '\'': Row5|Row8,
+    '=': Row6|Row8,
+    '"': Row7|Row8,
+    '[': Row12|Row2|Row8,   // Cent sign
+    '.': Row12|Row3|Row8,
+    '<': Row12|Row4|Row8,
+    '(': Row12|Row5|Row8,
+    '+': Row12|Row6|Row8,
+    '|': Row12|Row7|Row8,
+    '!': Row11|Row2|Row8,
+    '$': Row11|Row3|Row8,
+    '*': Row11|Row4|Row8,
+    ')': Row11|Row5|Row8,
+    ';': Row11|Row6|Row8,
+    '^': Row11|Row7|Row8,   // Logical not
+    ']': Row0|Row2|Row8,
+    ',': Row0|Row3|Row8,
+    '%': Row0|Row4|Row8,
+    '_': Row0|Row5|Row8,
+    '>': Row0|Row6|Row8,
+    '?': Row0|Row7|Row8,
+}
+
+// Encode returns the punches for the character c. Lower case letters not
+// present in the table are encoded as upper case. false is returned if the
+// character cannot be encoded.
+func (t HollerithTable) Encode(c rune) (uint16, bool) {
+    col, ok := t[c]
+    if !ok {
+        col, ok = t[unicode.ToUpper(c)]
+    }
+    return col&kColumnMask, ok
+}
+
+// CardDeck is implemented by card deck media attached to a card reader.
+// ReadCard returns the column images of the next card in the deck. io.EOF is
+// returned when the deck is exhausted.
+type CardDeck interface {
+    ReadCard() ([]uint16, error)
+}
+
+// TextDeck is a card deck read from plain text. Each line of text is one card.
+// Lines shorter than 80 columns are padded with blanks, longer lines are
+// truncated.
+type TextDeck struct {
+    s *bufio.Scanner
+    table HollerithTable
+    line int
+}
+
+// NewTextDeck returns a deck that reads cards from the text r using the
+// translation table. If table is nil, Hollerith029 is used.
+func NewTextDeck(r io.Reader, table HollerithTable) *TextDeck {
+    if table == nil {
+        table = Hollerith029
+    }
+    return &TextDeck{
+        s: bufio.NewScanner(r),
+        table: table,
+    }
+}
+
+// ReadCard implements the CardDeck interface. An error is returned if a line
+// contains a character that is not in the translation table.
+func (d *TextDeck) ReadCard() ([]uint16, error) {
+    if !d.s.Scan() {
+        if err := d.s.Err(); err != nil {
+            return nil, err
+        }
+        return nil, io.EOF
+    }
+    d.line++
+
+    cols := make([]uint16, CardColumns)
+    text := strings.TrimRight(d.s.Text(), "\r")
+    i := 0
+    for _, c := range text {
+        if i == CardColumns {
+            break
+        }
+        col, ok := d.table.Encode(c)
+        if !ok {
+            return nil, fmt.Errorf("card %d: column %d: no punches for %q", d.line, i + 1, c)
+        }
+        cols[i] = col
+        i++
+    }
+    return cols, nil
+}
+
+// BinaryDeck is a card deck read from column images. Each card is 80 columns
+// of 16-bit little endian words with the punches in the low order 12 bits.
+type BinaryDeck struct {
+    r io.Reader
+}
+
+// NewBinaryDeck returns a deck that reads column images from r.
+func NewBinaryDeck(r io.Reader) *BinaryDeck {
+    return &BinaryDeck{r: r}
+}
+
+// ReadCard implements the CardDeck interface. An error is returned if the
+// deck ends part way through a card.
+func (d *BinaryDeck) ReadCard() ([]uint16, error) {
+    b := make([]byte, CardColumns*2)
+    if _, err := io.ReadFull(d.r, b); err != nil {
+        if err == io.ErrUnexpectedEOF {
+            return nil, errors.New("short card image")
+        }
+        return nil, err
+    }
+
+    cols := make([]uint16, CardColumns)
+    for i := range cols {
+        cols[i] = (uint16(b[2*i]) | uint16(b[2*i + 1]) << 8)&kColumnMask
+    }
+    return cols, nil
+}
+
+// CardPunch is punch media. PunchCard punches the column images of one card.
+type CardPunch interface {
+    PunchCard(cols []uint16) error
+}
+
+// BinaryPunch punches column images in the format read by BinaryDeck.
+type BinaryPunch struct {
+    w io.Writer
+}
+
+// NewBinaryPunch returns a card punch that writes column images to w.
+func NewBinaryPunch(w io.Writer) *BinaryPunch {
+    return &BinaryPunch{w: w}
+}
+
+// PunchCard implements the CardPunch interface.
+func (p *BinaryPunch) PunchCard(cols []uint16) error {
+    b := make([]byte, CardColumns*2)
+    for i := 0; i < CardColumns && i < len(cols); i++ {
+        c := cols[i]&kColumnMask
+        b[2*i], b[2*i + 1] = byte(c), byte(c >> 8)
+    }
+    _, err := p.w.Write(b)
+    return err
+}
+
+// CardArt punches cards as ASCII art, one line for each row with an o for
+// each punch.
+type CardArt struct {
+    w io.Writer
+}
+
+// NewCardArt returns a card punch that renders cards on w.
+func NewCardArt(w io.Writer) *CardArt {
+    return &CardArt{w: w}
+}
+
+// PunchCard implements the CardPunch interface.
+func (p *CardArt) PunchCard(cols []uint16) error {
+    rows := []string{"12", "11", " 0", " 1", " 2", " 3", " 4", " 5", " 6", " 7", " 8", " 9"}
+    b := bufio.NewWriter(p.w)
+    fmt.Fprintf(b, "  +%s+\n", strings.Repeat("-", CardColumns))
+    for i, row := range rows {
+        punch := Row12 >> uint(i)
+        line := []byte(strings.Repeat(" ", CardColumns))
+        for j := 0; j < CardColumns && j < len(cols); j++ {
+            if cols[j]&punch != 0 {
+                line[j] = 'o'
+            }
+        }
+        fmt.Fprintf(b, "%s|%s|\n", row, line)
+    }
+    fmt.Fprintf(b, "  +%s+\n", strings.Repeat("-", CardColumns))
+    return b.Flush()
+}
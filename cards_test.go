@@ -0,0 +1,241 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "errors"
+    "strings"
+
+    "testing"
+)
+
+func TestTextDeck(t *testing.T) {
+    deck := NewTextDeck(strings.NewReader("HELLO, world 09\n\n"), nil)
+
+    card, err := deck.ReadCard()
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[int]uint16{
+        0: Row12|Row8,          // H
+        5: Row0|Row3|Row8,      // ,
+        6: 0,                   // space
+        7: Row0|Row6,           // w
+        13: Row0,               // 0
+        14: Row9,               // 9
+        79: 0,
+    }
+    for col, punches := range want {
+        if card[col] != punches {
+            t.Errorf("column %d: have: %04o, want: %04o", col + 1, card[col], punches)
+        }
+    }
+
+    card, err = deck.ReadCard()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for col, punches := range card {
+        if punches != 0 {
+            t.Errorf("column %d: have: %04o, want: 0", col + 1, punches)
+        }
+    }
+
+    _, err = deck.ReadCard()
+    if err == nil {
+        t.Error("have: nil, want: EOF")
+    }
+
+    deck = NewTextDeck(strings.NewReader("{\n"), nil)
+    _, err = deck.ReadCard()
+    if err == nil {
+        t.Error("have: nil, want: err")
+    }
+}
+
+func TestBinaryDeck(t *testing.T) {
+    var b bytes.Buffer
+    for col := 0; col < CardColumns; col++ {
+        b.WriteByte(byte(col))
+        b.WriteByte(0360 | byte(col >> 8))
+    }
+    b.WriteByte(0)
+
+    deck := NewBinaryDeck(&b)
+    card, err := deck.ReadCard()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for col, punches := range card {
+        if punches != uint16(col) {
+            t.Errorf("column %d: have: %04o, want: %04o", col + 1, punches, col)
+        }
+    }
+
+    _, err = deck.ReadCard()
+    if err == nil {
+        t.Error("have: nil, want: err")
+    }
+}
+
+func TestCardReader(t *testing.T) {
+    n := NewNova()
+    err := n.Attach(DevCDR, strings.NewReader("A1\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    d := n.devices[DevCDR]
+
+    d.write(ioNIO, ioS, 0)
+    if !d.test(ioBN) {
+        t.Error("busy: have: false, want: true")
+    }
+    var card []uint16
+    for {
+        waitDone(t, d)
+        if d.read(ioDIB, 0)&cdrEndOfCard != 0 {
+            break
+        }
+        card = append(card, d.read(ioDIA, ioC))
+    }
+    if len(card) != CardColumns {
+        t.Fatalf("columns: have: %d, want: %d", len(card), CardColumns)
+    }
+    if card[0] != Row12|Row1 || card[1] != Row1 {
+        t.Errorf("have: %04o %04o, want: %04o %04o", card[0], card[1], Row12|Row1, Row1)
+    }
+
+    // Deck is now exhausted
+    d.write(ioNIO, ioS, 0)
+    waitDone(t, d)
+    status := d.read(ioDIB, 0)
+    if status != cdrHopperEmpty {
+        t.Errorf("status: have: %06o, want: %06o", status, cdrHopperEmpty)
+    }
+
+    // Reset stops the card without a late end of card
+    n.Attach(DevCDR, strings.NewReader("A\n"))
+    n.SetRate(DevCDR, Unthrottled)
+    d.write(ioNIO, ioS, 0)
+    waitDone(t, d)
+    d.reset()
+    for i := 0; i < 100; i++ {
+        if d.test(ioDN) {
+            t.Fatalf("reset: have: done, status: %06o, want: idle", d.read(ioDIB, 0))
+        }
+    }
+}
+
+// brokenDeck is a deck whose cards cannot be read.
+type brokenDeck struct{}
+
+func (brokenDeck) ReadCard() ([]uint16, error) {
+    return nil, errBrokenPipe
+}
+
+func TestCardReaderError(t *testing.T) {
+    n := NewNova()
+    err := n.Attach(DevCDR, brokenDeck{})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    d := n.devices[DevCDR]
+    d.write(ioNIO, ioS, 0)
+    waitDone(t, d)
+    if status := d.read(ioDIB, 0); status != cdrReadCheck {
+        t.Errorf("status: have: %06o, want: %06o", status, cdrReadCheck)
+    }
+    if e := <-n.DeviceErrors(); e.Code != DevCDR || !errors.Is(e, errBrokenPipe) {
+        t.Errorf("have: %v, want: CDR broken pipe", e)
+    }
+
+    // A blank card is read in place of an ignored error
+    n.SetErrorPolicy(DevCDR, ErrorIgnore)
+    d.write(ioNIO, ioS, 0)
+    waitDone(t, d)
+    if status := d.read(ioDIB, 0); status != 0 {
+        t.Errorf("status: have: %06o, want: 0", status)
+    }
+    if col := d.read(ioDIA, ioC); col != 0 {
+        t.Errorf("column: have: %04o, want: 0", col)
+    }
+}
+
+func TestCardPunch(t *testing.T) {
+    n := NewNova()
+    var b bytes.Buffer
+    err := n.Attach(DevCDP, &b)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Punch two columns then stack the card
+    d := n.devices[DevCDP]
+    for _, col := range []uint16{Row12|Row1, Row0|Row9} {
+        d.write(ioDOA, ioS, col)
+        waitDone(t, d)
+        if status := d.read(ioDIB, 0); status != 0 {
+            t.Errorf("status: have: %06o, want: 0", status)
+        }
+    }
+    d.write(ioNIO, ioP, 0)
+
+    card, err := NewBinaryDeck(&b).ReadCard()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if card[0] != Row12|Row1 || card[1] != Row0|Row9 || card[2] != 0 {
+        t.Errorf("have: %04o, want: [%04o %04o 0000 ...]", card[:3], Row12|Row1, Row0|Row9)
+    }
+
+    var art bytes.Buffer
+    NewCardArt(&art).PunchCard(card)
+    lines := strings.Split(art.String(), "\n")
+    if lines[1] != "12|o"+strings.Repeat(" ", CardColumns - 1)+"|" {
+        t.Errorf("row 12: have: %q", lines[1])
+    }
+    if lines[12] != " 9| o"+strings.Repeat(" ", CardColumns - 2)+"|" {
+        t.Errorf("row 9: have: %q", lines[12])
+    }
+
+    // A partly punched card is stacked when the punch is detached
+    d.write(ioDOA, ioS, Row11)
+    waitDone(t, d)
+    if err := n.Detach(DevCDP); err != nil {
+        t.Fatal(err)
+    }
+    card, err = NewBinaryDeck(&b).ReadCard()
+    if err != nil || card[0] != Row11 {
+        t.Errorf("have: %04o %v, want: %04o", card[:1], err, Row11)
+    }
+
+    // A card that cannot be stacked is a punch check
+    n.Attach(DevCDP, brokenWriter{})
+    d.write(ioDOA, ioS, Row11)
+    waitDone(t, d)
+    d.write(ioNIO, ioP, 0)
+    waitFor(t, func() bool { return d.read(ioDIB, 0) == cdpPunchCheck })
+}
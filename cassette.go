@@ -0,0 +1,282 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "os"
+    "time"
+)
+
+// Cassette commands loaded by DOA.
+const (
+    casRead = iota          // Read block
+    casWrite                // Write block
+    casWriteMark            // Write file mark
+    casRewind               // Rewind to load point
+    casSpaceForward         // Space forward one block
+    casSpaceReverse         // Space reverse one block
+)
+
+// Cassette status bits returned by DIA.
+const (
+    casError     = 0100000  // Any error
+    casEOT       = 0040000  // End of recorded tape
+    casBOT       = 0020000  // Tape at load point
+    casFileMark  = 0010000  // File mark read or written
+    casWriteLock = 0004000  // Cassette cannot be written
+    casNotReady  = 0002000  // No cassette
+    casLength    = 0001000  // Block length differs from word count
+    casBadTape   = 0000400  // Tape image error
+)
+
+// Time taken by inter-block gaps and by a rewind.
+const (
+    kCasGap    = time.Millisecond*50
+    kCasRewind = time.Millisecond*100
+)
+
+// cassette emulates a cassette tape controller. DOA loads the command in bits
+// 13-15, DOB loads the memory address and DOC loads the word count. Setting
+// Busy executes the command, transferring block data by data channel. Done is
+// set when the command completes. DIA returns the status, DIB the current
+// memory address and DIC the length in words of the last block read. A
+// failure to read or write the tape image is a device error; it sets the bad
+// tape status unless the error policy is ErrorIgnore.
+type cassette struct {
+    controller
+    t *TapeImage
+    cmd uint16
+    addr uint16         // Memory address
+    count uint16        // Word count
+    length uint16       // Length of last block read
+    status uint16
+    buf []uint16        // Block being written
+    xfer <-chan struct{}    // Pending data channel transfer
+    moving bool         // Tape in motion
+}
+
+// newCassette creates a cassette controller that transfers rate words per
+// second.
+func newCassette(n *Nova, num, pri uint16, rate float32) *cassette {
+    d := &cassette{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+    }
+    go d.device(rate)
+    return d
+}
+
+func (d *cassette) device(rate float32) {
+    word := time.Duration(float32(time.Second)/rate)
+    t := time.NewTimer(time.Second * 1)
+    t.Stop()
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                if !t.Stop() && d.moving {
+                    <-t.C
+                }
+                d.moving = false
+                d.xfer = nil
+                d.status = 0
+                d.idle()
+            case ioDIA:
+                msg.data = d.status
+                d.start(msg, t, word)
+            case ioDIB:
+                msg.data = d.addr
+                d.start(msg, t, word)
+            case ioDIC:
+                msg.data = d.length
+                d.start(msg, t, word)
+            case ioDOA:
+                d.cmd = msg.data&07
+                d.start(msg, t, word)
+            case ioDOB:
+                d.addr = msg.data&kAddrMask
+                d.start(msg, t, word)
+            case ioDOC:
+                d.count = msg.data
+                d.start(msg, t, word)
+            case ioNIO:
+                d.start(msg, t, word)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-d.xfer:
+            d.xfer = nil
+            if d.cmd == casWrite {
+                // Block loaded from memory; record it unless the cassette
+                // was unloaded during the transfer
+                if d.t == nil {
+                    d.status |= casError | casNotReady
+                } else {
+                    d.record(d.t.WriteBlock(d.buf))
+                }
+                d.addr += uint16(len(d.buf))
+            }
+            d.finish()
+        case <-t.C:
+            d.moving = false
+            d.finish()
+        }
+    }
+}
+
+// start sets the controller state from the message flags, executing the
+// command if Busy is set.
+func (d *cassette) start(msg devmsg, t *time.Timer, word time.Duration) {
+    if msg.flags == ioS && d.state == devBusy {
+        // Command in progress
+        return
+    }
+    d.flags(msg)
+    if msg.flags != ioS {
+        return
+    }
+    if d.t == nil {
+        d.status = casError | casNotReady
+        d.complete()
+        return
+    }
+
+    d.status = 0
+    motion := kCasGap
+    switch d.cmd {
+    case casRead:
+        words, err := d.t.ReadBlock()
+        d.record(err)
+        if err == nil {
+            d.length = uint16(len(words))
+            if len(words) != int(d.count) {
+                d.status |= casLength
+            }
+            if len(words) > int(d.count) {
+                words = words[:d.count]
+            }
+            d.xfer = d.n.requestTransfer(d.addr, words, true)
+            d.addr += uint16(len(words))
+            motion += time.Duration(d.length)*word
+        }
+    case casWrite:
+        if d.t.WriteLocked() {
+            d.record(ErrWriteLocked)
+            break
+        }
+        d.buf = make([]uint16, d.count)
+        d.xfer = d.n.requestTransfer(d.addr, d.buf, false)
+        motion += time.Duration(d.count)*word
+    case casWriteMark:
+        d.record(d.t.WriteFileMark())
+    case casRewind:
+        d.t.Rewind()
+        motion = kCasRewind
+    case casSpaceForward:
+        _, err := d.t.ReadBlock()
+        d.record(err)
+    case casSpaceReverse:
+        d.record(d.t.SpaceReverse())
+    default:
+        d.status |= casError
+        motion = 0
+    }
+    d.moving = true
+    t.Reset(motion)
+}
+
+// record sets the status from the result of a tape operation.
+func (d *cassette) record(err error) {
+    switch err {
+    case nil:
+    case ErrFileMark:
+        d.status |= casFileMark
+    case io.EOF:
+        d.status |= casEOT
+    case ErrWriteLocked:
+        d.status |= casError | casWriteLock
+    default:
+        if d.fail(err) != ErrorIgnore {
+            d.status |= casError | casBadTape
+        }
+    }
+    if d.cmd == casWriteMark && err == nil {
+        d.status |= casFileMark
+    }
+}
+
+// finish completes the command once the tape has stopped and any data channel
+// transfer is complete.
+func (d *cassette) finish() {
+    if d.moving || d.xfer != nil {
+        return
+    }
+    if d.t != nil {
+        if d.t.AtLoadPoint() {
+            d.status |= casBOT
+        }
+        if d.t.WriteLocked() {
+            d.status |= casWriteLock
+        }
+    }
+    d.complete()
+}
+
+// mediaErrors reports that the cassette media can fail.
+func (d *cassette) mediaErrors() {}
+
+// attachMedia attaches a cassette. The media may be a *TapeImage or an
+// io.ReadSeeker containing a tape image; the cassette is write locked unless
+// the media can be written. An *os.File is write locked unless it was opened
+// for writing. A nil media unloads the cassette.
+func (d *cassette) attachMedia(media interface{}) error {
+    var t *TapeImage
+    switch m := media.(type) {
+    case nil:
+    case *TapeImage:
+        t = m
+    case *os.File:
+        t = NewTapeImage(m)
+        t.SetWriteLocked(!fileWritable(m))
+    case io.ReadSeeker:
+        t = NewTapeImage(m)
+    default:
+        return fmt.Errorf("%s: need *TapeImage or io.ReadSeeker media", deviceName(d.num))
+    }
+    d.sync(func() {
+        d.t = t
+    })
+    return nil
+}
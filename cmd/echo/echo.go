@@ -4,19 +4,28 @@ import (
     "os"
     "fmt"
     "time"
-    "bytes"
-    "io"
+    "flag"
 
-    "github.com/pkg/term"
     "github.com/rockview/nova"
 )
 
+var pty = flag.Bool("pty", false, "attach console to a pseudo-terminal")
+
 func fatal(err error) {
     fmt.Fprintf(os.Stderr, "echo: %v\n", err)
     os.Exit(1)
 }
 
 func main() {
+    flag.Parse()
+    if err := run(); err != nil {
+        fatal(err)
+    }
+}
+
+// run runs the echo program with the console attached to the controlling
+// terminal or to a pseudo-terminal.
+func run() error {
     var program = [...]uint16{
         0062677, // 00400:  IORST   
         0004417, // 00401:  JSR     .+17
@@ -48,91 +57,35 @@ func main() {
         0000012, // 00433:
     }
 
-    if true {
-    t, err := term.Open("/dev/tty")
-    if err != nil {
-        fatal(err)
-    }
-    defer t.Close()
-
-    err = term.RawMode(t)
-    if err != nil {
-        fatal(err)
-    }
-    defer t.Restore()
-
-    b := make([]byte, 1)
-    for {
-        n, err := t.Read(b)
-        if err == io.EOF {
-            fmt.Println("got EOF")
-        } else if err != nil {
-            fmt.Printf("got error: %d, %v\n", n, err)
-        } else {
-            fmt.Printf("got char: %d, %c\n", n, b[0])
-        }
-       time.Sleep(time.Second * 3)
-   }
-}
-
     n := nova.NewNova()
-    //n.Attach(nova.DevTTI, os.Stdin)
-    //n.Attach(nova.DevTTO, os.Stdout)
-
-    input := bytes.NewBufferString("abc\x04")
-    var output bytes.Buffer
-
-    n.Attach(nova.DevTTI, input)
-    n.Attach(nova.DevTTO, output)
-
-    n.LoadMemory(0400, program[:])
-    //n.Trace(0400, 1000000)
-
-    /*
-    _, err := n.Examine(0400)   // Load PC
-    if err != nil {
-        fatal(err)
-    }
-    for i := 0; i < 10; i++ {
-        state, _ := n.State()
-        fmt.Println(state)
-        ad, halt, err := n.InstStep()
+    if *pty {
+        p, err := n.AttachPty(nova.DevTTI, nova.DevTTO)
         if err != nil {
-            fatal(err)
+            return err
         }
-        if halt == 1 {
-            return
+        defer p.Close()
+        fmt.Printf("console: %s\n", p.Name())
+    } else {
+        t, err := n.AttachTerminal(nova.DevTTI, nova.DevTTO, "/dev/tty")
+        if err != nil {
+            return err
         }
+        defer t.Close()
     }
-    */
 
-    addr, err := n.WaitForHalt(time.Second * 1)
+    err := n.LoadMemory(0400, program[:])
     if err != nil {
-        fatal(err)
-    }
-    if addr != 00406 {
-        fatal(fmt.Errorf("wrong HALT address"))
+        return err
     }
+    n.Start(0400)
 
-    fmt.Printf("output: %v\n", output)
-
-    /*
-    b := make([]byte, 1)
-    for {
-        _, err = t.Read(b)
-        if err == io.EOF {
-            continue
-        }
-        if err != nil {
-            fatal(err)
-        }
-        if b[0] == 0x04 {   // ^D (EOT)
-            break
-        }
-        _, err = t.Write(b)
-        if err != nil {
-            fatal(err)
-        }
+    // Echo until ^D (EOT) is typed
+    addr, err := n.WaitForHalt(time.Hour)
+    if err != nil {
+        return err
+    }
+    if addr != 00406 {
+        return fmt.Errorf("wrong HALT address")
     }
-    */
+    return nil
 }
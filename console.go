@@ -40,6 +40,9 @@ import (
     "time"
     "io"
     "errors"
+    "net"
+    "sort"
+    "strconv"
 )
 
 // Reset implements the console RESET function. The processor is halted at the
@@ -247,6 +250,67 @@ func (n *Nova) State() (string, error) {
         n.pc, ir, n.ac[0], n.ac[1], n.ac[2], n.ac[3], carry, ion, DisasmWord(ir)), nil
 }
 
+// DeviceState is a snapshot of the state of a device on the I/O bus.
+type DeviceState struct {
+    Code int           // Device code
+    Name string        // Device mnemonic
+    Priority int       // Interrupt priority mask bit
+    Busy bool
+    Done bool
+    Interrupt bool     // Interrupt requested
+    Disabled bool      // Interrupt disabled by MSKO
+    Data uint16        // Contents of the device data buffer
+    Media string       // Attached media; empty if none
+}
+
+// String returns the device state in the format:
+// CODE NAME PRI BUSY DONE INT MSK DATA MEDIA.
+func (s DeviceState) String() string {
+    bit := func(b bool) int {
+        if b {
+            return 1
+        }
+        return 0
+    }
+    return fmt.Sprintf("%02o %-4s %2d  %d %d  %d %d  %06o  %s",
+        s.Code, s.Name, s.Priority, bit(s.Busy), bit(s.Done), bit(s.Interrupt),
+        bit(s.Disabled), s.Data, s.Media)
+}
+
+// Devices returns a snapshot of the state of every device on the I/O bus in
+// device code order. The processor may be running, in which case each device
+// is sampled between I/O instructions; the snapshot shows why a program
+// waiting for a device is still waiting.
+func (n *Nova) Devices() []DeviceState {
+    list := make([]DeviceState, 0, len(n.devices))
+    for num, d := range n.devices {
+        busy, done, data := d.snapshot()
+        list = append(list, DeviceState{
+            Code: int(num),
+            Name: deviceName(num),
+            Priority: int(d.priority()),
+            Busy: busy,
+            Done: done,
+            Data: data,
+        })
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].Code < list[j].Code
+    })
+
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    for i := range list {
+        s := &list[i]
+        s.Interrupt = n.interrupts&(1 << uint(s.Code)) != 0
+        s.Disabled = n.intdisable&(1 << uint(s.Code)) != 0
+        if a := n.attached[uint16(s.Code)]; a != nil {
+            s.Media = a.describe()
+        }
+    }
+    return list
+}
+
 // WaitForHalt waits for the processor to halt. If the processor halted within
 // the timeout period the current value of the program counter is returned. An
 // error is returned if the processor fails to halt within the timeout period.
@@ -259,27 +323,41 @@ func (n *Nova) WaitForHalt(timeout time.Duration) (int, error) {
     }
 }
 
-// Attach attaches media to a device. If the processor is running, the media is
-// not attached and an error is returned. If the device is not capable of input
-// or output or cannot support the provided media, an error is returned.
+// Attach attaches media to a device, replacing any media already attached.
+// Media may be attached while the processor is running, as an operator changes
+// tapes; the change takes effect between I/O instructions. Output buffered by
+// the previous media is flushed. If the device is not capable of input or
+// output or cannot support the provided media, an error is returned.
 func (n *Nova) Attach(code int, media interface{}) error {
-    if n.IsRunning() {
-        return errors.New("cannot attach to running processor")
+    num := uint16(code)&077
+    if err := n.attach(num, media); err != nil {
+        return err
     }
+    n.record(num, &attachment{
+        Attachment: Attachment{
+            Code: int(num),
+            Media: fmt.Sprintf("%T", media),
+        },
+    })
+    return nil
+}
 
-    num := uint16(code)&077
+// attach attaches media to device num.
+func (n *Nova) attach(num uint16, media interface{}) error {
     dev := n.devices[num]
     if dev == nil {
         return fmt.Errorf("%s: device not found", deviceName(num))
     }
 
     switch d := dev.(type) {
+    case mediaDriver:
+        return d.attachMedia(media)
     case inputDriver:
         s, ok := media.(io.Reader)
         if !ok {
             return fmt.Errorf("%s: need io.Reader media", deviceName(num))
         }
-        d.attach(s)
+        return d.attach(s)
     case outputDriver:
         s, ok := media.(io.Writer)
         if !ok {
@@ -293,6 +371,192 @@ func (n *Nova) Attach(code int, media interface{}) error {
     return nil
 }
 
+// Detach detaches the media from a device, or from every line of a
+// multiplexer device. The processor may be running. Output buffered by the
+// media is flushed and input read ahead from it is discarded. The media itself
+// is not closed unless it is a file attached with AttachFile. A device with no
+// media behaves as it does when no media was ever attached. If the device is
+// not capable of input or output, an error is returned.
+func (n *Nova) Detach(code int) error {
+    num := uint16(code)&077
+    dev := n.devices[num]
+    if dev == nil {
+        return fmt.Errorf("%s: device not found", deviceName(num))
+    }
+
+    var err error
+    switch d := dev.(type) {
+    case mediaDriver:
+        err = d.attachMedia(nil)
+    case inputDriver:
+        err = d.attach(nil)
+    case outputDriver:
+        d.attach(nil)
+    case lineDriver:
+        for line := 0; line < d.lines() && err == nil; line++ {
+            err = d.attachLine(line, nil)
+        }
+    default:
+        return fmt.Errorf("%s: not input/output device", deviceName(num))
+    }
+    if err != nil {
+        return err
+    }
+
+    n.record(num, nil)
+    return nil
+}
+
+// ReaderPosition returns the position of the tape in the paper tape reader
+// with device code code.
+func (n *Nova) ReaderPosition(code int) (TapePosition, error) {
+    d, err := n.tapeReader(code)
+    if err != nil {
+        return TapePosition{}, err
+    }
+    return d.tapeOp(-1), nil
+}
+
+// RewindReader rewinds the tape in the paper tape reader with device code
+// code. If the processor is running or the tape is not seekable, the tape is
+// not moved and an error is returned.
+func (n *Nova) RewindReader(code int) error {
+    return n.PositionReader(code, 0)
+}
+
+// PositionReader positions the tape in the paper tape reader with device code
+// code so that frame is the next frame read. If the processor is running or
+// the tape is not seekable, the tape is not moved and an error is returned.
+func (n *Nova) PositionReader(code, frame int) error {
+    if n.IsRunning() {
+        return errors.New("cannot position tape in running processor")
+    }
+    d, err := n.tapeReader(code)
+    if err != nil {
+        return err
+    }
+    if frame < 0 {
+        return fmt.Errorf("%s: invalid frame: %d", deviceName(d.num), frame)
+    }
+    if d.tapeOp(-1).Length < 0 {
+        return fmt.Errorf("%s: tape not seekable", deviceName(d.num))
+    }
+    d.tapeOp(frame)
+    return nil
+}
+
+// tapeReader returns the paper tape reader with device code code.
+func (n *Nova) tapeReader(code int) (*stdReader, error) {
+    num := uint16(code)&077
+    d, ok := n.devices[num].(*stdReader)
+    if !ok || d.keyboard {
+        return nil, fmt.Errorf("%s: not paper tape reader", deviceName(num))
+    }
+    return d, nil
+}
+
+// AttachPty attaches a newly allocated host pseudo-terminal to the input device
+// with code in and the output device with code out, typically DevTTI and
+// DevTTO. Host programs such as screen or minicom connect to the device named
+// by the returned Pty, which the caller should close when finished.
+func (n *Nova) AttachPty(in, out int) (*Pty, error) {
+    p, err := OpenPty()
+    if err != nil {
+        return nil, err
+    }
+    if err := n.attachConsole(in, out, p); err != nil {
+        p.Close()
+        return nil, err
+    }
+    return p, nil
+}
+
+// AttachTerminal opens the host terminal device name in raw mode and attaches
+// it to the input device with code in and the output device with code out.
+// The caller should close the returned Terminal to restore the terminal mode.
+func (n *Nova) AttachTerminal(in, out int, name string) (*Terminal, error) {
+    t, err := OpenTerminal(name)
+    if err != nil {
+        return nil, err
+    }
+    if err := n.attachConsole(in, out, t); err != nil {
+        t.Close()
+        return nil, err
+    }
+    return t, nil
+}
+
+// attachConsole attaches rw to the input and output devices.
+func (n *Nova) attachConsole(in, out int, rw io.ReadWriter) error {
+    if err := n.Attach(in, rw); err != nil {
+        return err
+    }
+    return n.Attach(out, rw)
+}
+
+// ListenConsole attaches a telnet port listening on the TCP network address
+// addr to the input device with code in and the output device with code out,
+// typically DevTTI and DevTTO. The port offers binary transmission. Clients
+// may disconnect and reconnect while the processor is running; output is
+// discarded while no client is connected. If log is not nil, the console
+// output is copied to it. The port is returned so that it can be closed by the
+// caller.
+func (n *Nova) ListenConsole(in, out int, addr string, log io.Writer) (*TelnetPort, error) {
+    p, err := ListenTelnet(addr)
+    if err != nil {
+        return nil, err
+    }
+    p.SetBinary(true)
+    p.SetLog(log)
+    if err := n.attachConsole(in, out, p); err != nil {
+        p.Close()
+        return nil, err
+    }
+    return p, nil
+}
+
+// AttachLine attaches media to a line of a multiplexer device, replacing any
+// media already attached. The processor may be running. A nil media detaches
+// the line. An error is returned if the device is not a multiplexer or has no
+// such line.
+func (n *Nova) AttachLine(code, line int, media io.ReadWriter) error {
+    num := uint16(code)&077
+    dev := n.devices[num]
+    if dev == nil {
+        return fmt.Errorf("%s: device not found", deviceName(num))
+    }
+    d, ok := dev.(lineDriver)
+    if !ok {
+        return fmt.Errorf("%s: not multiplexer device", deviceName(num))
+    }
+    return d.attachLine(line, media)
+}
+
+// ListenLines attaches telnet ports to count lines of a multiplexer device,
+// starting with line 0. Line i listens on the TCP port number port+i of host.
+// The ports are returned so that they can be closed by the caller. If an
+// error occurs, any ports already created are closed.
+func (n *Nova) ListenLines(code int, host string, port, count int) ([]*TelnetPort, error) {
+    var ports []*TelnetPort
+    for i := 0; i < count; i++ {
+        p, err := ListenTelnet(net.JoinHostPort(host, strconv.Itoa(port + i)))
+        if err == nil {
+            err = n.AttachLine(code, i, p)
+            if err != nil {
+                p.Close()
+            }
+        }
+        if err != nil {
+            for _, p := range ports {
+                p.Close()
+            }
+            return nil, err
+        }
+        ports = append(ports, p)
+    }
+    return ports, nil
+}
+
 const (
     // Request
     conReset int = iota
@@ -326,12 +590,20 @@ func (n *Nova) initRun() {
     case <-n.halt:
     default:
     }
+    n.fault()
 }
 
 // Processor stopped; waiting for key
 func (n *Nova) stopped() {
     for {
-        msg := <-n.con
+        var msg conmsg
+        select {
+        case msg = <-n.con:
+        case req := <-n.dch:
+            // Data channel operates while processor stopped
+            n.transfer(req)
+            continue
+        }
         switch msg.typ {
         case conReset:
             n.reset()
@@ -406,7 +678,7 @@ func (n *Nova) running() {
                 panic("running: invalid message type")
             }
         default:
-            if n.step() == cpuHalt {
+            if n.step() == cpuHalt || n.fault() {
                 n.halt <- struct{}{}
                 return
             }
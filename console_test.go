@@ -25,6 +25,12 @@ package nova
 import (
     "testing"
 
+    "bufio"
+    "bytes"
+    "io/ioutil"
+    "net"
+    "os"
+    "path/filepath"
     "time"
 )
 
@@ -194,3 +200,201 @@ func TestInstStep(t *testing.T) {
         }
     }
 }
+
+func TestAttachPty(t *testing.T) {
+    n := NewNova()
+    p, err := n.AttachPty(DevTTI, DevTTO)
+    if err != nil {
+        t.Skip(err)
+    }
+    defer p.Close()
+
+    slave, err := os.OpenFile(p.Name(), os.O_RDWR, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer slave.Close()
+
+    // Output passes through unchanged in raw mode
+    d := n.devices[DevTTO]
+    d.write(ioDOA, ioS, '\n')
+    b := make([]byte, 2)
+    n1, err := slave.Read(b)
+    if err != nil || string(b[:n1]) != "\n" {
+        t.Errorf("have: %q %v, want: %q", b[:n1], err, "\n")
+    }
+}
+
+func TestListenConsole(t *testing.T) {
+    n := NewNova()
+    var log bytes.Buffer
+    p, err := n.ListenConsole(DevTTI, DevTTO, "127.0.0.1:0", &log)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+
+    negotiation := []byte{
+        telIAC, telWILL, telEcho,
+        telIAC, telWILL, telSGA,
+        telIAC, telDO, telSGA,
+        telIAC, telWILL, telBinary,
+        telIAC, telDO, telBinary,
+    }
+    d := n.devices[DevTTO]
+    for _, char := range []byte{'A', 0200|'B'} {
+        // Each client sees output after connecting
+        c, err := net.Dial("tcp", p.Addr().String())
+        if err != nil {
+            t.Fatal(err)
+        }
+        c.SetDeadline(time.Now().Add(time.Second))
+        r := bufio.NewReader(c)
+        for i, want := range negotiation {
+            have, err := r.ReadByte()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if have != want {
+                t.Errorf("byte %d: have: %d, want: %d", i, have, want)
+            }
+        }
+
+        d.write(ioDOA, ioS, uint16(char))
+        have, err := r.ReadByte()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if have != char {
+            t.Errorf("have: %03o, want: %03o", have, char)
+        }
+        c.Close()
+        waitFor(t, func() bool { return !p.Connected() })
+    }
+    if log.String() != "A\302" {
+        t.Errorf("log: have: %q, want: %q", log.String(), "A\302")
+    }
+}
+
+func TestDetach(t *testing.T) {
+    n := NewNova()
+    n.LoadMemory(0, []uint16{0000000}) // JMP 0
+    var a, b bytes.Buffer
+    err := n.Attach(DevPTP, bufio.NewWriter(&a))
+    if err != nil {
+        t.Fatal(err)
+    }
+    n.Start(0)
+    defer n.Stop()
+
+    d := n.devices[DevPTP]
+    punch := func(c uint16) {
+        d.write(ioDOA, ioS, c)
+        waitDone(t, d)
+    }
+
+    // Media is swapped while running; buffered output is flushed
+    punch('A')
+    if err := n.Attach(DevPTP, &b); err != nil {
+        t.Fatal(err)
+    }
+    punch('B')
+    if err := n.Detach(DevPTP); err != nil {
+        t.Fatal(err)
+    }
+    punch('C')
+    if a.String() != "A" || b.String() != "B" {
+        t.Errorf("have: %q %q, want: %q %q", a.String(), b.String(), "A", "B")
+    }
+
+    // Reader with no media completes at once
+    if err := n.Attach(DevPTR, bytes.NewReader([]byte("x"))); err != nil {
+        t.Fatal(err)
+    }
+    if err := n.Detach(DevPTR); err != nil {
+        t.Fatal(err)
+    }
+    r := n.devices[DevPTR]
+    r.write(ioNIO, ioS, 0)
+    waitDone(t, r)
+    if c := r.read(ioDIA, 0); c != 0 {
+        t.Errorf("have: %03o, want: 0", c)
+    }
+
+    // Every line of a multiplexer is detached
+    q := n.devices[DevQTY].(*qty)
+    for line := 0; line < q.lines(); line++ {
+        if err := n.AttachLine(DevQTY, line, new(bytes.Buffer)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := n.Detach(DevQTY); err != nil {
+        t.Fatal(err)
+    }
+    q.sync(func() {
+        for line, l := range q.m.lines {
+            if l.rw != nil {
+                t.Errorf("line %d: have: attached, want: detached", line)
+            }
+        }
+    })
+
+    if err := n.Detach(DevCRC); err == nil {
+        t.Error("have: nil, want: error")
+    }
+}
+
+func TestDevices(t *testing.T) {
+    n := NewNova()
+    var b bytes.Buffer
+    n.Attach(DevPTP, &b)
+    state := func(code int) DeviceState {
+        for _, s := range n.Devices() {
+            if s.Code == code {
+                return s
+            }
+        }
+        t.Fatalf("%02o: have: none, want: device", code)
+        return DeviceState{}
+    }
+
+    d := n.devices[DevPTP]
+    d.write(ioDOA, ioS, 'A')
+    s := state(DevPTP)
+    if !s.Busy || s.Done || s.Interrupt || s.Data != 'A' || s.Media != "*bytes.Buffer" {
+        t.Errorf("busy: have: %+v", s)
+    }
+    waitDone(t, d)
+    n.msko(1 << priPTP)
+    s = state(DevPTP)
+    if s.Busy || !s.Done || !s.Interrupt || !s.Disabled {
+        t.Errorf("done: have: %+v", s)
+    }
+    if have, want := s.String(), "13 PTP  13  0 1  1 1  000101  *bytes.Buffer"; have != want {
+        t.Errorf("have: %q, want: %q", have, want)
+    }
+    if s := state(DevPTR); s.Busy || s.Done || s.Media != "" {
+        t.Errorf("PTR: have: %+v", s)
+    }
+
+    // Card reader part way through a card
+    deck := filepath.Join(t.TempDir(), "deck")
+    if err := ioutil.WriteFile(deck, []byte("A\n"), 0666); err != nil {
+        t.Fatal(err)
+    }
+    if err := n.AttachFile(DevCDR, deck, AttachDefault); err != nil {
+        t.Fatal(err)
+    }
+    defer n.Close()
+    n.SetRate(DevCDR, 10)
+    r := n.devices[DevCDR]
+    r.write(ioNIO, ioS, 0)
+    waitDone(t, r)
+    s = state(DevCDR)
+    if !s.Busy || !s.Done || s.Data != Row12|Row1 {
+        t.Errorf("CDR: have: %+v", s)
+    }
+    if want := deck+" (default, text card deck)"; s.Media != want {
+        t.Errorf("CDR media: have: %q, want: %q", s.Media, want)
+    }
+}
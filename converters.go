@@ -0,0 +1,241 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "time"
+    "fmt"
+)
+
+// Number of analog channels.
+const kAnalogChannels = 16
+
+// adc emulates an analog to digital converter. DOA selects the channel in bits
+// 12-15 and setting Busy starts a conversion of the selected channel. Done is
+// set when the conversion completes and DIA returns the converted value in
+// two's complement, sign extended to 16 bits.
+type adc struct {
+    controller
+    in *AnalogInput
+    channel int
+    attached time.Time  // Time media was attached
+}
+
+// newADC creates an analog to digital converter that performs rate
+// conversions per second.
+func newADC(n *Nova, num, pri uint16, rate float32) *adc {
+    d := &adc{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+    }
+    go d.device(rate)
+    return d
+}
+
+func (d *adc) device(rate float32) {
+    period := time.Duration(float32(time.Second)/rate)
+    t := time.NewTimer(time.Second * 1)
+    t.Stop()
+    expired := true
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                d.channel = 0
+                d.idle()
+            case ioDIA:
+                msg.data = d.data
+                d.startFlags(msg, t, period, &expired)
+            case ioDOA:
+                d.channel = int(msg.data)%kAnalogChannels
+                d.startFlags(msg, t, period, &expired)
+            case ioNIO, ioDIB, ioDOB, ioDIC, ioDOC:
+                d.startFlags(msg, t, period, &expired)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-t.C:
+            // Conversion complete
+            expired = true
+            d.data = 0
+            if d.in != nil && d.in.Source != nil {
+                v := d.in.Source.Sample(d.channel, time.Since(d.attached))
+                d.data = encodeAnalog(v, analogBits(d.in.Bits))
+            }
+            d.complete()
+        }
+    }
+}
+
+// startFlags sets the device state from the message flags, starting the
+// conversion timer if Busy is set.
+func (d *adc) startFlags(msg devmsg, t *time.Timer, period time.Duration, expired *bool) {
+    if msg.flags == ioS {
+        // Start conversion; delay until conversion time has elapsed
+        if !t.Stop() && !*expired {
+            <-t.C
+        }
+        t.Reset(period)
+        *expired = false
+    }
+    d.flags(msg)
+}
+
+// attachMedia attaches an AnalogInput or a SignalSource to the converter. A
+// SignalSource is converted with the default resolution. A nil media detaches
+// the input, which then reads as zero.
+func (d *adc) attachMedia(media interface{}) error {
+    var in *AnalogInput
+    switch m := media.(type) {
+    case nil:
+    case *AnalogInput:
+        in = m
+    case SignalSource:
+        in = &AnalogInput{Source: m}
+    default:
+        return fmt.Errorf("%s: need *AnalogInput or SignalSource media", deviceName(d.num))
+    }
+    d.sync(func() {
+        d.in = in
+        d.attached = time.Now()
+    })
+    return nil
+}
+
+// Digital to analog converter status bits returned by DIB.
+const dacError = 0100000    // Sample could not be output
+
+// dac emulates a digital to analog converter. DOB selects the channel in bits
+// 12-15 and DOA outputs the two's complement value in the low order bits to the
+// selected channel. The converter settles immediately, so setting Busy sets
+// Done at once. DIB returns the converter status; Clear clears it.
+type dac struct {
+    controller
+    out *AnalogOutput
+    channel int
+    status uint16
+    attached time.Time  // Time media was attached
+}
+
+// newDAC creates a digital to analog converter.
+func newDAC(n *Nova, num, pri uint16) *dac {
+    d := &dac{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+    }
+    go d.device()
+    return d
+}
+
+func (d *dac) device() {
+    for {
+        msg := <-d.dev
+        switch msg.typ {
+        case ioRST:
+            d.channel = 0
+            d.status = 0
+            d.idle()
+        case ioDOA:
+            d.data = msg.data
+            if d.out != nil && d.out.Sink != nil {
+                s := Sample{
+                    Time: time.Since(d.attached),
+                    Channel: d.channel,
+                    Value: decodeAnalog(d.data, analogBits(d.out.Bits)),
+                }
+                if err := d.out.Sink.WriteSample(s); err != nil && d.fail(err) == ErrorStatus {
+                    d.status |= dacError
+                }
+            }
+            d.settle(msg)
+        case ioDOB:
+            d.channel = int(msg.data)%kAnalogChannels
+            d.settle(msg)
+        case ioDIB:
+            msg.data = d.status
+            d.settle(msg)
+        case ioNIO, ioDIA, ioDIC, ioDOC:
+            d.settle(msg)
+        case ioSKP:
+            msg.data = d.skip(msg)
+        case ioSYNC:
+            msg.fn()
+        default:
+            d.invalid(msg)
+        }
+        d.dev <- msg    // Ack
+    }
+}
+
+// settle sets the device state from the message flags. The output settles
+// immediately.
+func (d *dac) settle(msg devmsg) {
+    if msg.flags == ioC {
+        d.status = 0
+    }
+    d.flags(msg)
+    d.complete()
+}
+
+// mediaErrors reports that the DAC media can fail.
+func (d *dac) mediaErrors() {}
+
+// attachMedia attaches an AnalogOutput or a SampleSink to the converter. A
+// SampleSink receives samples converted with the default resolution. A nil
+// media detaches the output. The previous sink is flushed if it buffers
+// samples.
+func (d *dac) attachMedia(media interface{}) error {
+    var out *AnalogOutput
+    switch m := media.(type) {
+    case nil:
+    case *AnalogOutput:
+        out = m
+    case SampleSink:
+        out = &AnalogOutput{Sink: m}
+    default:
+        return fmt.Errorf("%s: need *AnalogOutput or SampleSink media", deviceName(d.num))
+    }
+    var err error
+    d.sync(func() {
+        if d.out != nil {
+            err = flush(d.out.Sink)
+        }
+        d.out = out
+        d.attached = time.Now()
+    })
+    return err
+}
@@ -51,6 +51,19 @@ type Nova struct {
     sr uint16                   // Switch register
     con chan conmsg             // Console channel
     halt chan struct{}          // Signals machine HALT
+    dch chan dchreq             // Data channel requests
+    errs chan *DeviceError      // Device error events
+    policies map[uint16]int     // Device error policies
+    faulted int32               // Device error requests HALT
+    attached map[uint16]*attachment // Attached media
+}
+
+// Data channel request.
+type dchreq struct {
+    addr uint16                 // First memory address
+    words []uint16              // Words transferred
+    in bool                     // Transfer into memory
+    done chan struct{}          // Signals transfer complete
 }
 
 const (
@@ -66,6 +79,10 @@ func NewNova() *Nova {
         devices: make(map[uint16]driver),
         con: make(chan conmsg),
         halt: make(chan struct{}),
+        dch: make(chan dchreq, 64),
+        errs: make(chan *DeviceError, kDeviceErrors),
+        policies: make(map[uint16]int),
+        attached: make(map[uint16]*attachment),
     }
     n.addDevices()
     go n.processor()
@@ -346,6 +363,7 @@ func (n *Nova) step() int {
     }
 
     // Handle data channel requests
+    n.dataChannel()
 
     // Handle interrupts
     if (n.flags&cpuION) != 0 {
@@ -394,8 +412,8 @@ func (n *Nova) reset() {
     n.flags &^= cpuION
     n.mu.Lock()
     n.interrupts = 0
-    n.mu.Unlock()
     n.intdisable = 0
+    n.mu.Unlock()
 }
 
 // Assert MSKO
@@ -406,7 +424,9 @@ func (n *Nova) msko(mask uint16) {
             flags |= (1 << d.code())
         }
     }
+    n.mu.Lock()
     n.intdisable = flags
+    n.mu.Unlock()
 }
 
 // Assert INTA
@@ -422,6 +442,41 @@ func (n *Nova) inta() uint16 {
     return 0
 }
 
+// Service pending data channel requests.
+func (n *Nova) dataChannel() {
+    for {
+        select {
+        case req := <-n.dch:
+            n.transfer(req)
+        default:
+            return
+        }
+    }
+}
+
+// Perform a data channel transfer.
+func (n *Nova) transfer(req dchreq) {
+    for i := range req.words {
+        addr := (req.addr + uint16(i))&kAddrMask
+        if req.in {
+            n.m[addr] = req.words[i]
+        } else {
+            req.words[i] = n.m[addr]
+        }
+    }
+    req.done <- struct{}{}
+}
+
+// requestTransfer queues a data channel transfer of words starting at addr.
+// If in is true the words are stored in memory, otherwise they are loaded from
+// memory. The returned channel signals when the transfer is complete. Devices
+// must continue to service controller messages while a transfer is pending.
+func (n *Nova) requestTransfer(addr uint16, words []uint16, in bool) <-chan struct{} {
+    req := dchreq{addr, words, in, make(chan struct{}, 1)}
+    n.dch <- req
+    return req.done
+}
+
 func (n *Nova) setInt(num uint16) {
     n.mu.Lock()
     n.interrupts |= (1 << num)
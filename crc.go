@@ -0,0 +1,149 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+// CRC polynomials.
+const (
+    CRC16     = 0x8005  // x^16 + x^15 + x^2 + 1
+    CRCCCITT  = 0x1021  // x^16 + x^12 + x^5 + 1
+)
+
+// CRC generator mode bits loaded by DOB.
+const (
+    crcCCITT    = 0000001   // CCITT polynomial, otherwise CRC-16
+    crcReflect  = 0000002   // Least significant bit first
+    crcWord     = 0000004   // Accumulate both bytes, left byte first
+)
+
+// crcgen emulates a CRC generator. DOB selects the polynomial, bit order and
+// data length, DOC presets the CRC register, DOA accumulates the character in
+// bits 8-15, or the whole word, and DIA returns the register. Clear sets the
+// register to zero after the transfer. Each operation completes immediately,
+// so setting Busy sets Done at once.
+type crcgen struct {
+    controller
+    mode uint16
+    crc uint16
+}
+
+// newCRC creates a CRC generator.
+func newCRC(n *Nova, num, pri uint16) *crcgen {
+    d := &crcgen{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+    }
+    go d.device()
+    return d
+}
+
+func (d *crcgen) device() {
+    for {
+        msg := <-d.dev
+        switch msg.typ {
+        case ioRST:
+            d.mode = 0
+            d.crc = 0
+            d.idle()
+        case ioDIA:
+            msg.data = d.crc
+        case ioDOA:
+            if d.mode&crcWord != 0 {
+                d.accumulate(byte(msg.data >> 8))
+            }
+            d.accumulate(byte(msg.data))
+        case ioDOB:
+            d.mode = msg.data
+        case ioDOC:
+            d.crc = msg.data
+        case ioNIO, ioDIB, ioDIC:
+        case ioSKP:
+            msg.data = d.skip(msg)
+        case ioSYNC:
+            msg.fn()
+        default:
+            d.invalid(msg)
+            d.dev <- msg    // Ack
+            continue
+        }
+        if msg.typ != ioSKP && msg.typ != ioSYNC {
+            if msg.flags == ioC {
+                d.crc = 0
+            }
+            d.flags(msg)
+            d.complete()
+        }
+        d.dev <- msg    // Ack
+    }
+}
+
+// accumulate adds c to the CRC register using the selected polynomial and bit
+// order.
+func (d *crcgen) accumulate(c byte) {
+    poly := uint16(CRC16)
+    if d.mode&crcCCITT != 0 {
+        poly = CRCCCITT
+    }
+    d.crc = crcUpdate(d.crc, c, poly, d.mode&crcReflect != 0)
+}
+
+// crcUpdate adds the 8 bits of c to crc using the polynomial poly. If lsbFirst
+// is set, bits are processed least significant first, as they are transmitted
+// on a serial line, and the CRC register is reflected.
+func crcUpdate(crc uint16, c byte, poly uint16, lsbFirst bool) uint16 {
+    if lsbFirst {
+        rpoly := reverse16(poly)
+        crc ^= uint16(c)
+        for i := 0; i < 8; i++ {
+            if crc&1 != 0 {
+                crc = crc >> 1 ^ rpoly
+            } else {
+                crc >>= 1
+            }
+        }
+        return crc
+    }
+
+    crc ^= uint16(c) << 8
+    for i := 0; i < 8; i++ {
+        if crc&0x8000 != 0 {
+            crc = crc << 1 ^ poly
+        } else {
+            crc <<= 1
+        }
+    }
+    return crc
+}
+
+// reverse16 returns v with its bits in reverse order.
+func reverse16(v uint16) uint16 {
+    var r uint16
+    for i := 0; i < 16; i++ {
+        r = r << 1 | v&1
+        v >>= 1
+    }
+    return r
+}
@@ -0,0 +1,89 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "testing"
+)
+
+func TestCRC(t *testing.T) {
+    check := []byte("123456789")
+    tests := []struct{
+        name string
+        mode uint16
+        init uint16
+        want uint16
+    }{
+        {"ARC", crcReflect, 0, 0xbb3d},
+        {"CCITT-FALSE", crcCCITT, 0xffff, 0x29b1},
+        {"XMODEM", crcCCITT, 0, 0x31c3},
+        {"KERMIT", crcCCITT|crcReflect, 0, 0x2189},
+        {"BUYPASS", 0, 0, 0xfee8},
+    }
+
+    n := NewNova()
+    d := n.devices[DevCRC]
+    for _, test := range tests {
+        d.write(ioDOB, 0, test.mode)
+        d.write(ioDOC, 0, test.init)
+        for _, c := range check {
+            d.write(ioDOA, 0, uint16(c))
+        }
+        if crc := d.read(ioDIA, ioC); crc != test.want {
+            t.Errorf("%s: have: %#04x, want: %#04x", test.name, crc, test.want)
+        }
+
+        // Word mode accumulates left byte first
+        d.write(ioDOB, 0, test.mode|crcWord)
+        d.write(ioDOC, 0, test.init)
+        for i := 0; i < len(check) - 1; i += 2 {
+            d.write(ioDOA, 0, uint16(check[i])<<8 | uint16(check[i+1]))
+        }
+        d.write(ioDOB, 0, test.mode)
+        d.write(ioDOA, 0, uint16(check[len(check) - 1]))
+        if crc := d.read(ioDIA, 0); crc != test.want {
+            t.Errorf("%s word: have: %#04x, want: %#04x", test.name, crc, test.want)
+        }
+    }
+
+    // Clear
+    d.write(ioNIO, ioC, 0)
+    if crc := d.read(ioDIA, 0); crc != 0 {
+        t.Errorf("clear: have: %#04x, want: 0", crc)
+    }
+
+    // Busy sets Done at once
+    d.write(ioNIO, ioS, 0)
+    if !d.test(ioBZ) || !d.test(ioDN) {
+        t.Error("start: have: busy or not done, want: done")
+    }
+
+    // Invalid messages leave the register alone
+    d.write(ioDOC, 0, 0177777)
+    c := d.(*crcgen)
+    c.dev <- devmsg{typ: ioSYNC + 1, flags: ioC}
+    <-c.dev
+    if crc := d.read(ioDIA, 0); crc != 0177777 {
+        t.Errorf("invalid: have: %#04x, want: 0177777", crc)
+    }
+}
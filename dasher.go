@@ -0,0 +1,303 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "bytes"
+)
+
+// Dasher D100/D200 control characters.
+const (
+    dasherBlinkEnable   = 003
+    dasherBlinkDisable  = 004
+    dasherBell          = 007
+    dasherHome          = 010
+    dasherTab           = 011
+    dasherNewLine       = 012
+    dasherEraseEOL      = 013
+    dasherErasePage     = 014
+    dasherCR            = 015
+    dasherBlinkOn       = 016
+    dasherBlinkOff      = 017
+    dasherCursor        = 020   // Followed by column and row
+    dasherUnderOn       = 024
+    dasherUnderOff      = 025
+    dasherUp            = 027
+    dasherRight         = 030
+    dasherLeft          = 031
+    dasherDown          = 032
+    dasherDimOn         = 034
+    dasherDimOff        = 035
+    dasherRS            = 036   // Prefix for D200 sequences and function keys
+    dasherRubout        = 0177
+)
+
+// D200 sequences following RS.
+const (
+    dasherReverseOn     = 'D'
+    dasherReverseOff    = 'E'
+    dasherF1            = 0161  // Function keys F1-F15 follow in sequence
+)
+
+// dasherANSI translates the Dasher control characters that have a direct
+// ANSI equivalent.
+var dasherANSI = map[byte]string{
+    dasherHome:         "\x1b[H",
+    dasherNewLine:      "\r\n",
+    dasherEraseEOL:     "\x1b[K",
+    dasherErasePage:    "\x1b[H\x1b[2J",
+    dasherUnderOn:      "\x1b[4m",
+    dasherUnderOff:     "\x1b[24m",
+    dasherUp:           "\x1b[A",
+    dasherRight:        "\x1b[C",
+    dasherLeft:         "\x1b[D",
+    dasherDown:         "\x1b[B",
+    dasherDimOn:        "\x1b[2m",
+    dasherDimOff:       "\x1b[22m",
+}
+
+// Output translation states.
+const (
+    dasherNormal = iota
+    dasherColumn        // Expecting cursor column
+    dasherRow           // Expecting cursor row
+    dasherPrefix        // Expecting character following RS
+)
+
+// DasherWriter translates the output of a program driving a Dasher D100/D200
+// display to ANSI/VT100 sequences. Characters are 7 bits; the parity bit is
+// ignored. Control characters without an ANSI equivalent, such as roll
+// enable and read cursor address, are discarded.
+type DasherWriter struct {
+    w io.Writer
+    state int
+    col byte
+    blink bool      // Blinking is enabled
+}
+
+// NewDasherWriter returns a writer that translates Dasher output to ANSI
+// sequences written to w.
+func NewDasherWriter(w io.Writer) *DasherWriter {
+    return &DasherWriter{w: w, blink: true}
+}
+
+// Write implements the io.Writer interface.
+func (d *DasherWriter) Write(p []byte) (int, error) {
+    var b bytes.Buffer
+    for _, c := range p {
+        c &= 0177
+        switch d.state {
+        case dasherColumn:
+            d.col = c
+            d.state = dasherRow
+            continue
+        case dasherRow:
+            // Columns 0-79, rows 0-23
+            fmt.Fprintf(&b, "\x1b[%d;%dH", c%24 + 1, d.col%80 + 1)
+            d.state = dasherNormal
+            continue
+        case dasherPrefix:
+            switch c {
+            case dasherReverseOn:
+                b.WriteString("\x1b[7m")
+            case dasherReverseOff:
+                b.WriteString("\x1b[27m")
+            }
+            d.state = dasherNormal
+            continue
+        }
+
+        if s, ok := dasherANSI[c]; ok {
+            b.WriteString(s)
+            continue
+        }
+        switch c {
+        case dasherCursor:
+            d.state = dasherColumn
+        case dasherRS:
+            d.state = dasherPrefix
+        case dasherBlinkEnable:
+            d.blink = true
+        case dasherBlinkDisable:
+            d.blink = false
+        case dasherBlinkOn:
+            if d.blink {
+                b.WriteString("\x1b[5m")
+            }
+        case dasherBlinkOff:
+            b.WriteString("\x1b[25m")
+        case dasherBell, dasherTab, dasherCR:
+            b.WriteByte(c)
+        default:
+            if c >= ' ' {
+                b.WriteByte(c)
+            }
+        }
+    }
+    if _, err := d.w.Write(b.Bytes()); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+// ansiKeys translates the ANSI/VT100 key sequences sent by host terminals,
+// without the leading ESC, to Dasher key codes.
+var ansiKeys = map[string]string{
+    "[A":   string(rune(dasherUp)),
+    "[B":   string(rune(dasherDown)),
+    "[C":   string(rune(dasherRight)),
+    "[D":   string(rune(dasherLeft)),
+    "OA":   string(rune(dasherUp)),
+    "OB":   string(rune(dasherDown)),
+    "OC":   string(rune(dasherRight)),
+    "OD":   string(rune(dasherLeft)),
+    "[H":   string(rune(dasherHome)),
+    "OH":   string(rune(dasherHome)),
+    "[1~":  string(rune(dasherHome)),
+    "OP":   dasherKey(1),
+    "OQ":   dasherKey(2),
+    "OR":   dasherKey(3),
+    "OS":   dasherKey(4),
+    "[11~": dasherKey(1),
+    "[12~": dasherKey(2),
+    "[13~": dasherKey(3),
+    "[14~": dasherKey(4),
+    "[15~": dasherKey(5),
+    "[17~": dasherKey(6),
+    "[18~": dasherKey(7),
+    "[19~": dasherKey(8),
+    "[20~": dasherKey(9),
+    "[21~": dasherKey(10),
+    "[23~": dasherKey(11),
+    "[24~": dasherKey(12),
+    "[25~": dasherKey(13),
+    "[26~": dasherKey(14),
+    "[28~": dasherKey(15),
+}
+
+// dasherKey returns the code sent by Dasher function key n.
+func dasherKey(n int) string {
+    return string([]byte{dasherRS, byte(dasherF1 + n - 1)})
+}
+
+// DasherReader translates the input of a host terminal to the codes sent by
+// a Dasher keyboard. Cursor keys, home and function keys F1-F15 are
+// translated and backspace is sent as rubout. Unrecognised escape sequences
+// are passed through unchanged.
+type DasherReader struct {
+    r io.Reader
+    in []byte       // Untranslated input
+    out []byte      // Translated input
+}
+
+// NewDasherReader returns a reader that translates host terminal input read
+// from r to Dasher key codes.
+func NewDasherReader(r io.Reader) *DasherReader {
+    return &DasherReader{r: r}
+}
+
+// Read implements the io.Reader interface.
+func (d *DasherReader) Read(p []byte) (int, error) {
+    for len(d.out) == 0 {
+        buf := make([]byte, 64)
+        n, err := d.r.Read(buf)
+        d.in = append(d.in, buf[:n]...)
+        d.translate(err != nil)
+        if err != nil && len(d.out) == 0 {
+            return 0, err
+        }
+    }
+    n := copy(p, d.out)
+    d.out = d.out[n:]
+    return n, nil
+}
+
+// translate moves the translated input to the output. An incomplete escape
+// sequence at the end of the input is kept until more input arrives unless
+// it is a lone ESC or final is set.
+func (d *DasherReader) translate(final bool) {
+    for len(d.in) > 0 {
+        c := d.in[0]
+        if c == 010 {
+            d.out = append(d.out, dasherRubout)
+            d.in = d.in[1:]
+            continue
+        }
+        if c != 033 || len(d.in) == 1 {
+            d.out = append(d.out, c)
+            d.in = d.in[1:]
+            continue
+        }
+
+        // Escape sequence; find the final character
+        seq := d.in[1:]
+        n := 0
+        switch {
+        case seq[0] == 'O':
+            if len(seq) > 1 {
+                n = 2
+            }
+        case seq[0] == '[':
+            for i := 1; i < len(seq); i++ {
+                if seq[i] >= 0100 && seq[i] <= 0176 {
+                    n = i + 1
+                    break
+                }
+            }
+        default:
+            n = -1
+        }
+        if n == 0 {
+            if !final {
+                return
+            }
+            n = len(seq)
+        }
+        if n < 0 {
+            d.out = append(d.out, c)
+            d.in = d.in[1:]
+            continue
+        }
+        if key, ok := ansiKeys[string(seq[:n])]; ok {
+            d.out = append(d.out, key...)
+        } else {
+            d.out = append(d.out, d.in[:n + 1]...)
+        }
+        d.in = d.in[n + 1:]
+    }
+}
+
+// Dasher translates between a host terminal and a program driving a Dasher
+// display and keyboard. It can be attached to a multiplexer line or used as
+// both the console input and output media.
+type Dasher struct {
+    *DasherReader
+    *DasherWriter
+}
+
+// NewDasher returns a Dasher translating the host terminal rw.
+func NewDasher(rw io.ReadWriter) *Dasher {
+    return &Dasher{NewDasherReader(rw), NewDasherWriter(rw)}
+}
@@ -0,0 +1,110 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "io"
+    "io/ioutil"
+    "strings"
+
+    "testing"
+)
+
+func TestDasherWriter(t *testing.T) {
+    tests := []struct{
+        in string
+        want string
+    }{
+        {"AB\012", "AB\r\n"},
+        {"\014", "\x1b[H\x1b[2J"},
+        {"\020\012\005X", "\x1b[6;11HX"},
+        {"\013\027\030\031\032", "\x1b[K\x1b[A\x1b[C\x1b[D\x1b[B"},
+        {"\016B\017", "\x1b[5mB\x1b[25m"},
+        {"\004\016B", "B"},
+        {"\034\035\024\025", "\x1b[2m\x1b[22m\x1b[4m\x1b[24m"},
+        {"\036DX\036E", "\x1b[7mX\x1b[27m"},
+        {"\301\022", "A"},  // Parity stripped, roll enable discarded
+    }
+    for _, test := range tests {
+        var b bytes.Buffer
+        w := NewDasherWriter(&b)
+        // Sequences may be split across writes
+        for _, c := range []byte(test.in) {
+            w.Write([]byte{c})
+        }
+        if b.String() != test.want {
+            t.Errorf("%q: have: %q, want: %q", test.in, b.String(), test.want)
+        }
+    }
+}
+
+func TestDasherReader(t *testing.T) {
+    tests := []struct{
+        in string
+        want string
+    }{
+        {"abc\r", "abc\r"},
+        {"\x1b[A\x1b[B\x1b[C\x1b[D", "\027\032\030\031"},
+        {"\x1bOH\x1b[H", "\010\010"},
+        {"\x1bOP\x1b[15~\x1b[28~", "\036\161\036\165\036\177"},
+        {"\010", "\177"},
+        {"\x1b[99~\x1b", "\x1b[99~\x1b"},
+        {"\x1bO", "\x1bO"},
+    }
+    for _, test := range tests {
+        b, err := ioutil.ReadAll(NewDasherReader(strings.NewReader(test.in)))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if string(b) != test.want {
+            t.Errorf("%q: have: %q, want: %q", test.in, b, test.want)
+        }
+    }
+
+    // Sequence split across reads
+    r, w := io.Pipe()
+    d := NewDasherReader(r)
+    go func() {
+        w.Write([]byte("\x1b["))
+        w.Write([]byte("Ax"))
+        w.Close()
+    }()
+    b, _ := ioutil.ReadAll(d)
+    if string(b) != "\027x" {
+        t.Errorf("split: have: %q, want: %q", b, "\027x")
+    }
+
+    // Split after the SS3 introducer
+    r, w = io.Pipe()
+    d = NewDasherReader(r)
+    go func() {
+        w.Write([]byte("\x1bO"))
+        w.Write([]byte("P"))
+        w.Close()
+    }()
+    b, _ = ioutil.ReadAll(d)
+    if string(b) != "\036\161" {
+        t.Errorf("split SS3: have: %q, want: %q", b, "\036\161")
+    }
+}
@@ -0,0 +1,284 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "time"
+)
+
+// Modem is implemented by line media that provide modem control signals.
+// Media that do not implement Modem behave as a permanently connected line
+// with carrier present.
+type Modem interface {
+    Carrier() bool      // Data carrier detected
+    Ring() bool         // Ring indicator
+    SetDTR(on bool)     // Data terminal ready
+}
+
+// DCM status bits returned by DIB. The line number is returned in bits 10-15.
+const (
+    dcmRecv  = 0100000  // Character received
+    dcmXmit  = 0040000  // Transmitter ready
+    dcmModem = 0020000  // Modem status changed
+)
+
+// DCM modem status bits returned by DIC.
+const (
+    dcmCarrier = 0000001    // Carrier detected
+    dcmRing    = 0000002    // Ring indicator
+    dcmDTR     = 0000004    // Data terminal ready
+)
+
+// How often modem signals are sampled while a line has a Modem attached.
+const kModemPoll = time.Millisecond*10
+
+// modemState is the modem state of a line.
+type modemState struct {
+    carrier bool
+    ring bool
+    dtr bool
+    changed bool    // Carrier or ring has changed
+}
+
+// dcm emulates a data communications multiplexer with modem control. Done is
+// set while any line has received a character, has finished transmitting a
+// character or has a change of carrier or ring. DIB returns the status and
+// number of the next line requiring service, giving priority to modem changes
+// and then received characters. DIA returns the character received on the line
+// last reported by DIB and DIC returns its modem status. DOA transmits the
+// character in bits 8-15 on the line specified by bits 2-7. DOB sets DTR on the
+// line specified by bits 2-7 from bit 15. DTR is off following a reset. A line
+// that cannot be written is detached unless the error policy is ErrorIgnore.
+type dcm struct {
+    controller
+    m *mux
+    modems []modemState
+    poll *time.Ticker   // Modem signal sampling; nil without modems
+    line int    // Line last reported by DIB
+}
+
+// newDCM creates a multiplexer with count lines that transmit rate characters
+// per second.
+func newDCM(n *Nova, num, pri uint16, count int, rate float32) *dcm {
+    d := &dcm{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        m: newMux(count, rate),
+        modems: make([]modemState, count),
+    }
+    go d.device()
+    return d
+}
+
+func (d *dcm) device() {
+    for {
+        var poll <-chan time.Time
+        if d.poll != nil {
+            poll = d.poll.C
+        }
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                d.m.reset()
+                for i := range d.modems {
+                    d.setDTR(i, false)
+                    d.modems[i].changed = false
+                }
+                d.pollModems()
+            case ioDIA:
+                msg.data = uint16(d.m.receive(d.line))
+            case ioDIB:
+                msg.data = d.status()
+            case ioDIC:
+                msg.data = d.modemStatus(d.line)
+            case ioDOA:
+                line := int(msg.data >> 8)&077
+                if line < len(d.m.lines) {
+                    d.m.transmit(line, byte(msg.data))
+                }
+            case ioDOB:
+                line := int(msg.data >> 8)&077
+                if line < len(d.m.lines) {
+                    d.setDTR(line, msg.data&1 != 0)
+                }
+            case ioNIO, ioDOC:
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.update()
+            d.dev <- msg    // Ack
+        case e := <-d.m.events:
+            if d.m.event(e, d.fail) != nil {
+                if e.typ == muxDrop {
+                    d.sample(e.line)
+                    d.pollModems()
+                }
+                d.update()
+            }
+        case <-poll:
+            for i := range d.modems {
+                d.sample(i)
+            }
+            d.update()
+        }
+    }
+}
+
+// pollModems starts sampling modem signals if any line has a Modem attached
+// and stops it otherwise. Sampling is restarted so that a tick from before a
+// reset or an attachment is discarded.
+func (d *dcm) pollModems() {
+    if d.poll != nil {
+        stopTicker(d.poll)
+        d.poll = nil
+    }
+    for i := range d.m.lines {
+        if _, ok := d.m.lines[i].rw.(Modem); ok {
+            d.poll = time.NewTicker(kModemPoll)
+            return
+        }
+    }
+}
+
+// setDTR sets DTR on a line.
+func (d *dcm) setDTR(line int, on bool) {
+    d.modems[line].dtr = on
+    if m, ok := d.m.lines[line].rw.(Modem); ok {
+        m.SetDTR(on)
+    }
+}
+
+// sample samples the carrier and ring signals of a line.
+func (d *dcm) sample(line int) {
+    carrier, ring := true, false
+    rw := d.m.lines[line].rw
+    if rw == nil {
+        carrier = false
+    } else if m, ok := rw.(Modem); ok {
+        carrier, ring = m.Carrier(), m.Ring()
+    }
+    s := &d.modems[line]
+    if carrier != s.carrier || ring != s.ring {
+        s.carrier, s.ring = carrier, ring
+        s.changed = true
+    }
+}
+
+// modemStatus returns the modem signals of a line.
+func (d *dcm) modemStatus(line int) uint16 {
+    var status uint16
+    s := d.modems[line]
+    if s.carrier {
+        status |= dcmCarrier
+    }
+    if s.ring {
+        status |= dcmRing
+    }
+    if s.dtr {
+        status |= dcmDTR
+    }
+    return status
+}
+
+// status returns the status of the next line requiring service. Modem change
+// and transmitter ready conditions are cleared once reported.
+func (d *dcm) status() uint16 {
+    for i := range d.modems {
+        if d.modems[i].changed {
+            d.modems[i].changed = false
+            d.line = i
+            return dcmModem | uint16(i)
+        }
+    }
+    for i := range d.m.lines {
+        if d.m.lines[i].recv {
+            d.line = i
+            return dcmRecv | uint16(i)
+        }
+    }
+    for i := range d.m.lines {
+        l := &d.m.lines[i]
+        if l.xmit {
+            l.xmit = false
+            d.line = i
+            return dcmXmit | uint16(i)
+        }
+    }
+    return 0
+}
+
+// update sets Done if any line requires service.
+func (d *dcm) update() {
+    for i := range d.m.lines {
+        l := &d.m.lines[i]
+        if l.recv || l.xmit || d.modems[i].changed {
+            if d.state != devDone {
+                d.state = devDone
+                d.n.setInt(d.num)
+            }
+            return
+        }
+    }
+    d.idle()
+}
+
+// attachLine attaches media to a line. The media may be a Pty, a TelnetPort,
+// a net.Conn or any other io.ReadWriter.
+func (d *dcm) attachLine(line int, rw io.ReadWriter) error {
+    if line < 0 || line >= len(d.m.lines) {
+        return fmt.Errorf("%s: no line %d", deviceName(d.num), line)
+    }
+    d.sync(func() {
+        d.m.attach(line, rw)
+        d.setDTR(line, d.modems[line].dtr)
+        d.sample(line)
+        d.pollModems()
+    })
+    return nil
+}
+
+// lines returns the number of lines.
+func (d *dcm) lines() int {
+    return len(d.m.lines)
+}
+
+// mediaErrors reports that the multiplexer media can fail.
+func (d *dcm) mediaErrors() {}
+
+// setRate sets the character transmission time of every line.
+func (d *dcm) setRate(period time.Duration) {
+    d.sync(func() {
+        d.m.period = period
+    })
+}
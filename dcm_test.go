@@ -0,0 +1,114 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "net"
+
+    "testing"
+)
+
+func TestDCMModem(t *testing.T) {
+    n := NewNova()
+    p, err := ListenTelnet("127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+    err = n.AttachLine(DevDCM, 3, p)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    d := n.devices[DevDCM]
+    waitStatus := func(want uint16) {
+        t.Helper()
+        waitFor(t, func() bool {
+            return d.test(ioDN) && d.read(ioDIB, 0) == want
+        })
+    }
+
+    // Incoming call rings the line
+    c, err := net.Dial("tcp", p.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer c.Close()
+    waitStatus(dcmModem|3)
+    modem := d.read(ioDIC, 0)
+    if modem != dcmRing {
+        t.Errorf("modem: have: %06o, want: %06o", modem, dcmRing)
+    }
+
+    // Answer call
+    d.write(ioDOB, 0, 3 << 8 | 1)
+    waitStatus(dcmModem|3)
+    modem = d.read(ioDIC, 0)
+    if modem != dcmCarrier|dcmDTR {
+        t.Errorf("modem: have: %06o, want: %06o", modem, dcmCarrier|dcmDTR)
+    }
+
+    // Hang up
+    d.write(ioDOB, 0, 3 << 8)
+    waitStatus(dcmModem|3)
+    modem = d.read(ioDIC, 0)
+    if modem != 0 {
+        t.Errorf("modem: have: %06o, want: 0", modem)
+    }
+}
+
+func TestDCMPoll(t *testing.T) {
+    n := NewNova()
+    p, err := ListenTelnet("127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+    d := n.devices[DevDCM].(*dcm)
+    polling := func() (on bool) {
+        d.sync(func() {
+            on = d.poll != nil
+        })
+        return
+    }
+
+    // Modem signals are only sampled while a modem is attached
+    if polling() {
+        t.Errorf("have: polling, want: no polling")
+    }
+    err = n.AttachLine(DevDCM, 3, p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    d.reset()
+    if !polling() {
+        t.Errorf("have: no polling, want: polling")
+    }
+    err = n.AttachLine(DevDCM, 3, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if polling() {
+        t.Errorf("have: polling, want: no polling")
+    }
+}
@@ -22,16 +22,39 @@
 
 package nova
 
-import "io"
+import (
+    "io"
+    "fmt"
+    "sync/atomic"
+    "time"
+)
 
 // Device codes
 const (
+    DevMCAT = 006   // Multiprocessor communications adapter transmitter
+    DevMCAR = 007   // Multiprocessor communications adapter receiver
     DevTTI = 010    // Teletype input
     DevTTO = 011    // Teletype output
     DevPTR = 012    // Paper tape reader
     DevPTP = 013    // Paper type punch
+    DevPLT = 015    // Incremental plotter
+    DevCDR = 016    // Card reader
+    DevADCV = 021   // Analog to digital converter
     DevMTA = 022    // Magnetic tape
+    DevDACV = 023   // Digital to analog converter
+    DevDCM = 024    // Data communications multiplexer
+    DevCDP = 025    // Card punch
+    DevQTY = 030    // Asynchronous line multiplexer
+    DevIBM1 = 031   // Synchronous communications adapter
+    DevIBM2 = 032   // Second synchronous communications adapter
     DevDKP = 033    // Moving head disk
+    DevCAS = 034    // Cassette tape
+    DevCRC = 035    // CRC generator
+    DevIPB = 036    // Inter-processor buffer
+    DevDPI = 040    // Digital input
+    DevDPO = 041    // Digital output
+    DevDIO = 042    // Digital input/output
+    DevDIOT = 043   // Digital input/output timer
 
     DevTTI1 = 050   // Second teletype input
     DevTTO1 = 051   // Second teletype output
@@ -40,17 +63,33 @@ const (
 
     devMDV = 001    // Multiply/divide
     devRTC = 014    // Real time clock
+    devPIT = 026    // Programmable interval timer
+    devRTC1 = 054   // Second real time clock
     devCPU = 077    // CPU
 )
 
 // Device priorities
 const (
+    priDCM = 0
+    priIPB = 6
     priDKP = 7
+    priADCV = 8
+    priCRC = 8
+    priDIO = 8
+    priDACV = 8
     priMTA = 10
+    priCDR = 10
+    priCAS = 10
     priPTR = 11
+    priCDP = 12
+    priPLT = 12
+    priMCA = 12
     priRTC = 13
+    priIBM = 13
+    priPIT = 13
     priPTP = 13
     priTTI = 14
+    priQTY = 14
     priTTO = 15
 )
 
@@ -85,6 +124,7 @@ const (
 const (
     _ = iota + ioSKP
     ioRST       // Signal IORST
+    ioSYNC      // Run function in device goroutine
 )
 
 type driver interface {
@@ -94,11 +134,12 @@ type driver interface {
     test(t uint16) bool
     read(op, f uint16) uint16
     write(op, f uint16, data uint16)
+    snapshot() (busy, done bool, data uint16)
 }
 
 type inputDriver interface {
     driver
-    attach(w io.Reader)
+    attach(r io.Reader) error
 }
 
 type outputDriver interface {
@@ -106,6 +147,22 @@ type outputDriver interface {
     attach(w io.Writer)
 }
 
+// lineDriver is implemented by multiplexer devices with multiple lines. A nil
+// rw detaches the line. lines returns the number of lines.
+type lineDriver interface {
+    driver
+    attachLine(line int, rw io.ReadWriter) error
+    lines() int
+}
+
+// mediaDriver is implemented by devices that accept media other than a plain
+// io.Reader or io.Writer. attachMedia returns an error if the media is not
+// supported by the device. A nil media detaches the current media.
+type mediaDriver interface {
+    driver
+    attachMedia(media interface{}) error
+}
+
 // Device state.
 const (
     devIdle = iota
@@ -118,6 +175,167 @@ type devmsg struct {
     typ uint16      // Message type
     flags uint16    // Control or test flags
     data uint16     // Other message data
+    fn func()       // Function run by ioSYNC
+}
+
+// errorDriver is implemented by devices whose media can fail. Media errors are
+// handled under the device error policy.
+type errorDriver interface {
+    driver
+    mediaErrors()
+}
+
+// rateDriver is implemented by devices whose transfer rate can be changed.
+// period is the time taken to transfer one character, column or step.
+type rateDriver interface {
+    driver
+    setRate(period time.Duration)
+}
+
+// Device error policies.
+const (
+    ErrorHalt = iota    // Halt the processor
+    ErrorStatus         // Set the device error status
+    ErrorIgnore         // Continue as if the error had not occurred
+)
+
+// Size of the device error event buffer.
+const kDeviceErrors = 16
+
+// DeviceError is a machine event reporting a device I/O error.
+type DeviceError struct {
+    Code int        // Device code
+    Policy int      // Policy applied
+    Err error
+}
+
+func (e *DeviceError) Error() string {
+    return fmt.Sprintf("%s: %v", deviceName(uint16(e.Code)), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DeviceError) Unwrap() error {
+    return e.Err
+}
+
+// DeviceErrors returns the channel on which device errors are reported. Errors
+// are reported whatever the policy of the device. Errors are discarded if the
+// channel buffer is full.
+func (n *Nova) DeviceErrors() <-chan *DeviceError {
+    return n.errs
+}
+
+// SetErrorPolicy sets the policy applied when the device with code code reports
+// an error. With ErrorHalt, the default, the processor halts after the current
+// instruction. With ErrorStatus, the error is reported to the program in the
+// device status. With ErrorIgnore, the device continues as if the error had
+// not occurred.
+//
+// Policies apply to the character readers and writers, the card reader and
+// punch, the cassette, the DAC and the multiplexers. A multiplexer has no
+// error status; a line that cannot be written is detached unless the policy is
+// ErrorIgnore. An error is returned for other devices, whose media cannot fail.
+func (n *Nova) SetErrorPolicy(code, policy int) error {
+    num := uint16(code)&077
+    dev := n.devices[num]
+    if dev == nil {
+        return fmt.Errorf("%s: device not found", deviceName(num))
+    }
+    if _, ok := dev.(errorDriver); !ok {
+        return fmt.Errorf("%s: device reports no media errors", deviceName(num))
+    }
+    switch policy {
+    case ErrorHalt, ErrorStatus, ErrorIgnore:
+    default:
+        return fmt.Errorf("%s: invalid error policy: %d", deviceName(num), policy)
+    }
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.policies[num] = policy
+    return nil
+}
+
+// Unthrottled is the rate of a character device that transfers each character
+// as soon as possible.
+const Unthrottled = 0
+
+// SetRate sets the transfer rate of the character device with code code,
+// overriding the rate of the device being emulated. With Unthrottled, each
+// transfer completes as soon as possible; Busy is still set until the transfer
+// completes and Done, with its interrupt request, follows, so the program sees
+// the same sequence of flags at any rate. The rate may be
+// changed while the processor is running and applies from the next transfer.
+//
+// The rate is in characters per second, except that the rate of a card reader
+// or card punch is in columns per second and that of a plotter in steps per
+// second; a Plot with a Rate keeps its own rate. The rate of a multiplexer
+// applies to every line. An error is returned for devices that are not
+// character devices, such as tapes and converters.
+func (n *Nova) SetRate(code int, rate float32) error {
+    num := uint16(code)&077
+    dev := n.devices[num]
+    if dev == nil {
+        return fmt.Errorf("%s: device not found", deviceName(num))
+    }
+    d, ok := dev.(rateDriver)
+    if !ok {
+        return fmt.Errorf("%s: rate cannot be set", deviceName(num))
+    }
+    if rate < 0 {
+        return fmt.Errorf("%s: invalid rate: %g", deviceName(num), rate)
+    }
+    d.setRate(framePeriod(rate))
+    return nil
+}
+
+// framePeriod returns the time taken to transfer a character at rate
+// characters per second.
+func framePeriod(rate float32) time.Duration {
+    if rate == Unthrottled {
+        return 0
+    }
+    return time.Duration(float32(time.Second)/rate)
+}
+
+// stopTicker stops t and discards a tick that has already been sent, so that
+// it cannot be taken for a tick of the next period.
+func stopTicker(t *time.Ticker) {
+    t.Stop()
+    select {
+    case <-t.C:
+    default:
+    }
+}
+
+// stopTimer stops t and discards an expiry that has already been sent, so that
+// it cannot be taken for the expiry of a later period.
+func stopTimer(t *time.Timer) {
+    t.Stop()
+    select {
+    case <-t.C:
+    default:
+    }
+}
+
+// deviceError reports err from device num and returns the policy that the
+// device should apply.
+func (n *Nova) deviceError(num uint16, err error) int {
+    n.mu.Lock()
+    policy := n.policies[num]
+    n.mu.Unlock()
+    if policy == ErrorHalt {
+        atomic.StoreInt32(&n.faulted, 1)
+    }
+    select {
+    case n.errs <- &DeviceError{Code: int(num), Policy: policy, Err: err}:
+    default:
+    }
+    return policy
+}
+
+// fault reports and clears a HALT requested by a device error.
+func (n *Nova) fault() bool {
+    return atomic.SwapInt32(&n.faulted, 0) != 0
 }
 
 // Device controller.
@@ -142,13 +360,13 @@ func (c *controller) priority() uint16 {
 
 // reset performs device reset.
 func (c *controller) reset() {
-    c.dev <- devmsg{ioRST, 0, 0}
+    c.dev <- devmsg{typ: ioRST}
     <-c.dev
 }
 
 // test performs I/O SKP tests.
 func (c *controller) test(t uint16) bool {
-    c.dev <- devmsg{ioSKP, t, 0}
+    c.dev <- devmsg{typ: ioSKP, flags: t}
     ack := <-c.dev
     return ack.data == 1
 }
@@ -157,7 +375,7 @@ func (c *controller) test(t uint16) bool {
 // state from the flags specified by f. The data read from the device, if any,
 // is returned.
 func (c *controller) read(op, f uint16) uint16 {
-    c.dev <- devmsg{op, f, 0}
+    c.dev <- devmsg{typ: op, flags: f}
     ack := <-c.dev
     return ack.data
 }
@@ -165,10 +383,36 @@ func (c *controller) read(op, f uint16) uint16 {
 // write performs the I/O write operation specified by op on data and sets
 // the device state from the flags specified by f.
 func (c *controller) write(op, f uint16, data uint16) {
-    c.dev <- devmsg{op, f, data}
+    c.dev <- devmsg{typ: op, flags: f, data: data}
+    <-c.dev
+}
+
+// sync runs fn in the device goroutine, synchronizing it with the I/O
+// operations of the processor. It must not be called from the device
+// goroutine.
+func (c *controller) sync(fn func()) {
+    c.dev <- devmsg{typ: ioSYNC, fn: fn}
     <-c.dev
 }
 
+// flush flushes media that buffers its output, such as a *bufio.Writer,
+// before it is detached.
+func flush(media interface{}) error {
+    if f, ok := media.(interface{ Flush() error }); ok {
+        return f.Flush()
+    }
+    return nil
+}
+
+// snapshot returns the Busy and Done flags and the contents of the data
+// buffer.
+func (c *controller) snapshot() (busy, done bool, data uint16) {
+    c.sync(func() {
+        busy, done, data = c.state == devBusy, c.state == devDone, c.data
+    })
+    return busy, done, data
+}
+
 // skip returns skip condition specified by message flags.
 func (c *controller) skip(msg devmsg) uint16 {
     var result uint16
@@ -193,6 +437,37 @@ func (c *controller) skip(msg devmsg) uint16 {
     return result
 }
 
+// skipFlags returns skip condition specified by message flags for devices that
+// maintain separate Busy and Done flags.
+func skipFlags(msg devmsg, busy, done bool) uint16 {
+    var result bool
+    switch msg.flags {
+    case ioBN:
+        result = busy
+    case ioBZ:
+        result = !busy
+    case ioDN:
+        result = done
+    case ioDZ:
+        result = !done
+    }
+    if result {
+        return 1
+    }
+    return 0
+}
+
+// fail reports the device error err and returns the policy that the device
+// should apply.
+func (c *controller) fail(err error) int {
+    return c.n.deviceError(c.num, err)
+}
+
+// invalid reports an invalid device message. The message is otherwise ignored.
+func (c *controller) invalid(msg devmsg) {
+    c.fail(fmt.Errorf("invalid message type: %d", msg.typ))
+}
+
 // idle puts the device into an idle state.
 func (c *controller) idle() {
     c.state = devIdle
@@ -231,13 +506,34 @@ func deviceName(num uint16) string {
 // addDevices add all devices to the processor. Devices begin in an idle state
 // with no media attached.
 func (n *Nova) addDevices() {
-    n.devices[DevTTI] = newStdReader(n, DevTTI, priTTI, 10) // ASR-33
+    n.devices[DevMCAT] = newMCAT(n, DevMCAT, priMCA)
+    n.devices[DevMCAR] = newMCAR(n, DevMCAR, priMCA)
+    n.devices[DevTTI] = newKeyboard(n, DevTTI, priTTI, 10) // ASR-33
     n.devices[DevTTO] = newStdWriter(n, DevTTO, priTTO, 10) // ASR-33
     n.devices[DevPTR] = newStdReader(n, DevPTR, priPTR, 300) // 4011B
     n.devices[DevPTP] = newStdWriter(n, DevPTP, priPTP, 63.3)
-    n.devices[DevTTI1] = newStdReader(n, DevTTI1, priTTI, 10) // ASR-33
+    n.devices[DevTTI1] = newKeyboard(n, DevTTI1, priTTI, 10) // ASR-33
     n.devices[DevTTO1] = newStdWriter(n, DevTTO1, priTTO, 10) // ASR-33
     n.devices[DevPTR1] = newStdReader(n, DevPTR1, priPTR, 300) // 4011B
     n.devices[DevPTP1] = newStdWriter(n, DevPTP1, priPTP, 63.3)
-    n.devices[devRTC] = newrtc(n, 60)
+    n.devices[DevPLT] = newPlotter(n, DevPLT, priPLT, 300) // 4017
+    n.devices[DevCDR] = newCardReader(n, DevCDR, priCDR, 400) // 4016
+    n.devices[DevCDP] = newCardPunch(n, DevCDP, priCDP, 100)
+    n.devices[DevADCV] = newADC(n, DevADCV, priADCV, 25000)
+    n.devices[DevDACV] = newDAC(n, DevDACV, priDACV)
+    n.devices[DevDCM] = newDCM(n, DevDCM, priDCM, 16, 960)
+    n.devices[DevQTY] = newQTY(n, DevQTY, priQTY, 64, 960) // 4060
+    n.devices[DevIBM1] = newSCA(n, DevIBM1, priIBM, 300)    // 2400 bps
+    n.devices[DevIBM2] = newSCA(n, DevIBM2, priIBM, 300)    // 2400 bps
+    n.devices[DevCAS] = newCassette(n, DevCAS, priCAS, 750)
+    n.devices[DevCRC] = newCRC(n, DevCRC, priCRC)
+    n.devices[DevIPB] = newIPB(n, DevIPB, priIPB)
+    n.devices[DevDPI] = newDigitalIO(n, DevDPI, priDIO, true, false)
+    n.devices[DevDPO] = newDigitalIO(n, DevDPO, priDIO, false, true)
+    dio := newDigitalIO(n, DevDIO, priDIO, true, true)
+    n.devices[DevDIO] = dio
+    n.devices[DevDIOT] = newDIOT(n, DevDIOT, priDIO, dio)
+    n.devices[devRTC] = newrtc(n, devRTC, 60)
+    n.devices[devRTC1] = newrtc(n, devRTC1, 60)
+    n.devices[devPIT] = newPIT(n, devPIT, priPIT, 100000)
 }
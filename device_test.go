@@ -0,0 +1,177 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "errors"
+    "time"
+
+    "testing"
+)
+
+var errBrokenPipe = errors.New("broken pipe")
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write(b []byte) (int, error) {
+    return 0, errBrokenPipe
+}
+
+// waitFor waits for cond to hold, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+    t.Helper()
+    timeout := time.After(time.Second)
+    for !cond() {
+        select {
+        case <-timeout:
+            t.Fatal("have: timeout, want: condition")
+        default:
+        }
+    }
+}
+
+// waitDone waits for device d to set Done.
+func waitDone(t *testing.T, d driver) {
+    t.Helper()
+    waitFor(t, func() bool { return d.test(ioDN) })
+}
+
+func TestDeviceErrorHalt(t *testing.T) {
+    program := [...]uint16{
+        00000: 0061111, // DOAS 0,TTO
+        00001: 0063611, // SKPDN TTO
+        00002: 0000777, // JMP .-1
+        00003: 0000000, // JMP 0
+    }
+    n := NewNova()
+    n.LoadMemory(0, program[:])
+    err := n.Attach(DevTTO, brokenWriter{})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    n.Start(0)
+    _, err = n.WaitForHalt(time.Second)
+    if err != nil {
+        t.Fatal("have: timeout, want: halt")
+    }
+    select {
+    case e := <-n.DeviceErrors():
+        if e.Code != DevTTO || e.Policy != ErrorHalt || !errors.Is(e, errBrokenPipe) {
+            t.Errorf("have: %+v, want: TTO halt broken pipe", e)
+        }
+    default:
+        t.Error("have: no event, want: event")
+    }
+}
+
+func TestDeviceErrorStatus(t *testing.T) {
+    n := NewNova()
+    err := n.SetErrorPolicy(DevTTO, ErrorStatus)
+    if err != nil {
+        t.Fatal(err)
+    }
+    n.Attach(DevTTO, brokenWriter{})
+
+    d := n.devices[DevTTO]
+    d.write(ioDOA, ioS, 'x')
+    waitDone(t, d)
+    if status := d.read(ioDIB, 0); status != stdError {
+        t.Errorf("status: have: %06o, want: %06o", status, stdError)
+    }
+    if e := <-n.DeviceErrors(); e.Policy != ErrorStatus {
+        t.Errorf("policy: have: %d, want: %d", e.Policy, ErrorStatus)
+    }
+    if n.fault() {
+        t.Error("fault: have: true, want: false")
+    }
+
+    // Invalid messages are reported rather than fatal
+    d.write(ioSYNC + 1, 0, 0)
+    if e := <-n.DeviceErrors(); e.Code != DevTTO {
+        t.Errorf("have: %v, want: TTO error", e)
+    }
+
+    if err := n.SetErrorPolicy(DevTTO, 99); err == nil {
+        t.Error("have: nil, want: error")
+    }
+    if err := n.SetErrorPolicy(DevADCV, ErrorStatus); err == nil {
+        t.Error("ADC: have: nil, want: error")
+    }
+}
+
+func TestSetRate(t *testing.T) {
+    program := [...]uint16{
+        00000: 0061113, // DOAS 0,PTP
+        00001: 0063613, // SKPDN PTP
+        00002: 0000777, // JMP .-1
+        00003: 0014006, // DSZ 6
+        00004: 0000000, // JMP 0
+        00005: 0063077, // HALT
+        00006: 0000310, // 200 characters
+    }
+    n := NewNova()
+    n.LoadMemory(0, program[:])
+    var b bytes.Buffer
+    n.Attach(DevPTP, &b)
+    err := n.SetRate(DevPTP, Unthrottled)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Over 3 seconds at the punch rate
+    n.Start(0)
+    _, err = n.WaitForHalt(time.Second)
+    if err != nil {
+        n.Stop()
+        t.Fatal("have: timeout, want: halt")
+    }
+    if b.Len() != 200 {
+        t.Errorf("have: %d characters, want: 200", b.Len())
+    }
+
+    // A card takes over half a second at the punch rate
+    n.Attach(DevCDP, new(bytes.Buffer))
+    for _, code := range []int{DevPLT, DevCDR, DevCDP, DevQTY, DevDCM} {
+        if err := n.SetRate(code, Unthrottled); err != nil {
+            t.Error(err)
+        }
+    }
+    d := n.devices[DevCDP]
+    start := time.Now()
+    for i := 0; i < CardColumns; i++ {
+        d.write(ioDOA, ioS, Row12)
+        waitDone(t, d)
+    }
+    if elapsed := time.Since(start); elapsed > time.Millisecond*300 {
+        t.Errorf("card punch: have: %v, want: <300ms", elapsed)
+    }
+
+    if err := n.SetRate(DevCRC, 10); err == nil {
+        t.Error("CRC: have: nil, want: error")
+    }
+    if err := n.SetRate(DevPTP, -1); err == nil {
+        t.Error("rate: have: nil, want: error")
+    }
+}
@@ -0,0 +1,508 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "net"
+    "sync"
+    "time"
+    "bufio"
+    "strconv"
+    "strings"
+)
+
+// DigitalPort connects the 16 inputs and 16 outputs of a digital I/O
+// interface to the host. Inputs may be driven by SetInput, by a waveform
+// played with Play or by clients of a Unix socket created with ListenUnix.
+// Output changes are reported to functions registered with OnOutput and to
+// socket clients. A port may be attached to only one interface.
+type DigitalPort struct {
+    mu sync.Mutex
+    in uint16
+    out uint16
+    watch uint16                // Inputs whose changes are recorded
+    changed uint16              // Watched inputs changed since last taken
+    first uint16                // Inputs at the first recorded change
+    notify chan struct{}        // Inputs changed
+    outputs map[int]func(v uint16)
+    nextOutput int              // Key of next output function
+}
+
+// NewDigitalPort creates a port with all inputs and outputs 0.
+func NewDigitalPort() *DigitalPort {
+    return &DigitalPort{
+        notify: make(chan struct{}, 1),
+        outputs: make(map[int]func(v uint16)),
+    }
+}
+
+// SetInput sets the inputs.
+func (p *DigitalPort) SetInput(v uint16) {
+    p.mu.Lock()
+    changed := (v ^ p.in)&p.watch
+    p.in = v
+    if changed != 0 {
+        // Record the change so that it is not lost if the inputs change
+        // back before the interface sees it
+        if p.changed == 0 {
+            p.first = v
+        }
+        p.changed |= changed
+    }
+    p.mu.Unlock()
+    if changed != 0 {
+        select {
+        case p.notify <- struct{}{}:
+        default:
+        }
+    }
+}
+
+// setWatch selects the inputs whose changes are recorded and discards the
+// changes already recorded.
+func (p *DigitalPort) setWatch(mask uint16) {
+    p.mu.Lock()
+    p.watch = mask
+    p.changed = 0
+    p.mu.Unlock()
+}
+
+// changes returns the inputs that have changed since changes was last called
+// and the inputs present when the first of those changes occurred.
+func (p *DigitalPort) changes() (changed, first uint16) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    changed, first = p.changed, p.first
+    p.changed = 0
+    return changed, first
+}
+
+// Input returns the inputs.
+func (p *DigitalPort) Input() uint16 {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.in
+}
+
+// Output returns the outputs.
+func (p *DigitalPort) Output() uint16 {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.out
+}
+
+// OnOutput registers f to be called with the new outputs each time the
+// outputs are written. f is called by the device and must not block.
+func (p *DigitalPort) OnOutput(f func(v uint16)) {
+    p.onOutput(f)
+}
+
+// onOutput registers f as OnOutput does and returns a function that removes
+// it.
+func (p *DigitalPort) onOutput(f func(v uint16)) (remove func()) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    key := p.nextOutput
+    p.nextOutput++
+    p.outputs[key] = f
+    return func() {
+        p.mu.Lock()
+        delete(p.outputs, key)
+        p.mu.Unlock()
+    }
+}
+
+// setOutput sets the outputs.
+func (p *DigitalPort) setOutput(v uint16) {
+    p.mu.Lock()
+    p.out = v
+    outputs := make([]func(v uint16), 0, len(p.outputs))
+    for _, f := range p.outputs {
+        outputs = append(outputs, f)
+    }
+    p.mu.Unlock()
+    for _, f := range outputs {
+        f(v)
+    }
+}
+
+// Play drives the inputs from a waveform script read from r. Each line of the
+// script holds a time in seconds from the start of the waveform and the input
+// value to apply at that time, which may be written in decimal, octal with a
+// leading 0, or hexadecimal with a leading 0x. Blank lines and lines beginning
+// with # are ignored. The script is checked before Play returns and is then
+// played in the background.
+func (p *DigitalPort) Play(r io.Reader) error {
+    type step struct {
+        t time.Duration
+        v uint16
+    }
+    var steps []step
+
+    s := bufio.NewScanner(r)
+    for line := 1; s.Scan(); line++ {
+        text := strings.TrimSpace(s.Text())
+        if text == "" || text[0] == '#' {
+            continue
+        }
+        fields := strings.Fields(text)
+        if len(fields) != 2 {
+            return fmt.Errorf("line %d: need time and value", line)
+        }
+        secs, err := strconv.ParseFloat(fields[0], 64)
+        if err != nil {
+            return fmt.Errorf("line %d: %v", line, err)
+        }
+        v, err := strconv.ParseUint(fields[1], 0, 16)
+        if err != nil {
+            return fmt.Errorf("line %d: %v", line, err)
+        }
+        t := time.Duration(secs*float64(time.Second))
+        if len(steps) > 0 && t < steps[len(steps) - 1].t {
+            return fmt.Errorf("line %d: time out of order", line)
+        }
+        steps = append(steps, step{t, uint16(v)})
+    }
+    if err := s.Err(); err != nil {
+        return err
+    }
+
+    go func() {
+        start := time.Now()
+        for _, st := range steps {
+            time.Sleep(st.t - time.Since(start))
+            p.SetInput(st.v)
+        }
+    }()
+    return nil
+}
+
+// ListenUnix serves the port on a Unix socket at path. Each line sent by a
+// client sets the inputs to the value on the line, written as for Play. Each
+// time the outputs are written, the new value is sent to all clients as a line
+// containing a 6 digit octal number. The listener is returned so that it can
+// be closed by the caller; closing it disconnects the clients.
+func (p *DigitalPort) ListenUnix(path string) (net.Listener, error) {
+    l, err := net.Listen("unix", path)
+    if err != nil {
+        return nil, err
+    }
+
+    var mu sync.Mutex
+    clients := make(map[net.Conn]chan uint16)
+    remove := p.onOutput(func(v uint16) {
+        mu.Lock()
+        defer mu.Unlock()
+        for _, out := range clients {
+            select {
+            case out <- v:
+            default:
+                // Client not keeping up
+            }
+        }
+    })
+
+    go func() {
+        defer func() {
+            remove()
+            mu.Lock()
+            for c := range clients {
+                c.Close()
+            }
+            mu.Unlock()
+        }()
+        for {
+            c, err := l.Accept()
+            if err != nil {
+                return
+            }
+            out := make(chan uint16, 64)
+            mu.Lock()
+            clients[c] = out
+            mu.Unlock()
+
+            go func() {
+                for v := range out {
+                    if _, err := fmt.Fprintf(c, "%06o\n", v); err != nil {
+                        c.Close()
+                        return
+                    }
+                }
+            }()
+            go func() {
+                s := bufio.NewScanner(c)
+                for s.Scan() {
+                    v, err := strconv.ParseUint(strings.TrimSpace(s.Text()), 0, 16)
+                    if err == nil {
+                        p.SetInput(uint16(v))
+                    }
+                }
+                mu.Lock()
+                delete(clients, c)
+                mu.Unlock()
+                close(out)
+                c.Close()
+            }()
+        }
+    }()
+    return l, nil
+}
+
+// digitalIO emulates a 16-bit digital I/O interface; the DPI and DPO are the
+// input and output halves of the DIO. DIA returns the input register and DOA
+// loads the output register, which DIB returns. DOB loads the change mask. DOC
+// sets latching mode from bit 15. Setting Busy arms the interface and Done is
+// set, and an interrupt requested, when an input selected by the change mask
+// changes while armed; a change is seen even if the input changes back before
+// the interface responds. In latching mode the input register holds the inputs
+// present at the change until read by DIA; otherwise DIA returns the current
+// inputs.
+type digitalIO struct {
+    controller
+    p *DigitalPort
+    input bool          // Has inputs
+    output bool         // Has outputs
+    mask uint16         // Inputs that cause interrupts
+    latching bool
+    latch uint16        // Latched inputs
+    latched bool        // latch has not been read
+}
+
+// newDigitalIO creates a digital I/O interface with the specified inputs and
+// outputs.
+func newDigitalIO(n *Nova, num, pri uint16, input, output bool) *digitalIO {
+    d := &digitalIO{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        input: input,
+        output: output,
+        mask: 0177777,
+    }
+    go d.device()
+    return d
+}
+
+func (d *digitalIO) device() {
+    for {
+        var notify chan struct{}
+        if d.p != nil && d.input {
+            notify = d.p.notify
+        }
+
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                d.mask = 0177777
+                d.watch()
+                d.latching = false
+                d.latched = false
+                d.idle()
+            case ioDIA:
+                if d.input {
+                    msg.data = d.inputs()
+                }
+                d.armFlags(msg)
+            case ioDIB:
+                msg.data = d.data
+                d.armFlags(msg)
+            case ioDOA:
+                if d.output {
+                    d.data = msg.data
+                    if d.p != nil {
+                        d.p.setOutput(d.data)
+                    }
+                }
+                d.armFlags(msg)
+            case ioDOB:
+                d.mask = msg.data
+                d.watch()
+                d.armFlags(msg)
+            case ioDOC:
+                d.latching = msg.data&1 != 0
+                d.latched = false
+                d.armFlags(msg)
+            case ioNIO, ioDIC:
+                d.armFlags(msg)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-notify:
+            changed, first := d.p.changes()
+            if changed != 0 {
+                if d.latching && !d.latched {
+                    d.latch = first
+                    d.latched = true
+                }
+                d.complete()
+            }
+        }
+    }
+}
+
+// inputs returns the input register.
+func (d *digitalIO) inputs() uint16 {
+    if d.latching && d.latched {
+        d.latched = false
+        return d.latch
+    }
+    if d.p == nil {
+        return 0
+    }
+    return d.p.Input()
+}
+
+// armFlags sets the device state from the message flags. Changes recorded
+// before the interface is armed are discarded so that only later changes set
+// Done.
+func (d *digitalIO) armFlags(msg devmsg) {
+    if msg.flags == ioS && d.p != nil && d.input {
+        d.p.changes()
+    }
+    d.flags(msg)
+}
+
+// watch has the port record changes of the inputs selected by the change
+// mask.
+func (d *digitalIO) watch() {
+    if d.p != nil && d.input {
+        d.p.setWatch(d.mask)
+    }
+}
+
+// attachMedia attaches a DigitalPort to the interface. A nil media detaches
+// the port.
+func (d *digitalIO) attachMedia(media interface{}) error {
+    p, ok := media.(*DigitalPort)
+    if !ok && media != nil {
+        return fmt.Errorf("%s: need *DigitalPort media", deviceName(d.num))
+    }
+    d.sync(func() {
+        if d.p != nil && d.input {
+            d.p.setWatch(0)
+        }
+        d.p = p
+        d.watch()
+    })
+    return nil
+}
+
+// diot emulates the DIOT, the timer of the DIO, which samples the inputs of
+// the port attached to the DIO. DOA loads the interval in milliseconds, an
+// interval of 0 being 65536ms. Setting Busy starts the timer. When the
+// interval expires, the inputs are sampled into the data buffer, which DIA
+// returns, and Done is set. Clearing Busy stops the timer.
+type diot struct {
+    controller
+    dio *digitalIO
+    interval uint16
+}
+
+// newDIOT creates a timer for the DIO interface dio.
+func newDIOT(n *Nova, num, pri uint16, dio *digitalIO) *diot {
+    d := &diot{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        dio: dio,
+    }
+    go d.device()
+    return d
+}
+
+func (d *diot) device() {
+    timer := time.NewTimer(time.Second)
+    stopTimer(timer)
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                stopTimer(timer)
+                d.interval = 0
+                d.idle()
+            case ioDIA:
+                msg.data = d.data
+                d.timerFlags(msg, timer)
+            case ioDOA:
+                d.interval = msg.data
+                d.timerFlags(msg, timer)
+            case ioNIO, ioDIB, ioDOB, ioDIC, ioDOC:
+                d.timerFlags(msg, timer)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-timer.C:
+            d.data = d.sample()
+            d.complete()
+        }
+    }
+}
+
+// timerFlags sets the device state from the message flags, starting or
+// stopping the timer.
+func (d *diot) timerFlags(msg devmsg, timer *time.Timer) {
+    switch msg.flags {
+    case ioS:
+        interval := time.Duration(d.interval)*time.Millisecond
+        if d.interval == 0 {
+            interval = 0200000*time.Millisecond
+        }
+        stopTimer(timer)
+        timer.Reset(interval)
+    case ioC:
+        stopTimer(timer)
+    }
+    d.flags(msg)
+}
+
+// sample returns the inputs of the port attached to the DIO, or 0 if none is
+// attached.
+func (d *diot) sample() uint16 {
+    var in uint16
+    d.dio.sync(func() {
+        if d.dio.p != nil {
+            in = d.dio.p.Input()
+        }
+    })
+    return in
+}
@@ -0,0 +1,172 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bufio"
+    "fmt"
+    "net"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "testing"
+)
+
+func TestDigitalIO(t *testing.T) {
+    n := NewNova()
+    p := NewDigitalPort()
+    err := n.Attach(DevDIO, p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var outputs []uint16
+    p.OnOutput(func(v uint16) {
+        outputs = append(outputs, v)
+    })
+
+    d := n.devices[DevDIO]
+
+    // Outputs
+    d.write(ioDOA, 0, 0123)
+    if len(outputs) != 1 || outputs[0] != 0123 || p.Output() != 0123 {
+        t.Errorf("have: %v, want: [123]", outputs)
+    }
+
+    // Change interrupts on masked inputs only
+    d.write(ioDOB, 0, 0000017)
+    d.write(ioNIO, ioS, 0)
+    p.SetInput(0177400)
+    if d.test(ioDN) {
+        t.Error("done: have: true, want: false")
+    }
+    p.SetInput(0177401)
+    waitDone(t, d)
+    if in := d.read(ioDIA, ioS); in != 0177401 {
+        t.Errorf("have: %06o, want: %06o", in, 0177401)
+    }
+
+    // Latching holds the inputs present at the change
+    d.write(ioDOC, ioS, 1)
+    err = p.Play(strings.NewReader("# waveform\n0 0\n0.005 0x3\n0.010 07\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    waitDone(t, d)
+    waitFor(t, func() bool { return p.Input() == 07 })
+    if in := d.read(ioDIA, 0); in != 0 {
+        t.Errorf("latched: have: %06o, want: 0", in)
+    }
+    if in := d.read(ioDIA, 0); in != 07 {
+        t.Errorf("current: have: %06o, want: 07", in)
+    }
+
+    if err := p.Play(strings.NewReader("1 0\n0 1\n")); err == nil {
+        t.Error("have: nil, want: err")
+    }
+
+    // A change that is reversed at once is not lost, and the inputs present
+    // at the change are latched
+    d.read(ioDIA, ioC)
+    d.write(ioNIO, ioS, 0)
+    p.SetInput(017)
+    p.SetInput(03)
+    p.SetInput(07)
+    waitDone(t, d)
+    if in := d.read(ioDIA, ioC); in != 017 {
+        t.Errorf("latched: have: %06o, want: 017", in)
+    }
+}
+
+func TestDigitalPortSocket(t *testing.T) {
+    n := NewNova()
+    p := NewDigitalPort()
+    n.Attach(DevDPO, p)
+    path := filepath.Join(t.TempDir(), "dio")
+    l, err := p.ListenUnix(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer l.Close()
+
+    c, err := net.Dial("unix", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer c.Close()
+    c.SetDeadline(time.Now().Add(time.Second))
+
+    // The client is registered before its inputs are read
+    fmt.Fprintln(c, "0100")
+    waitFor(t, func() bool { return p.Input() == 0100 })
+
+    n.devices[DevDPO].write(ioDOA, 0, 0177777)
+    r := bufio.NewReader(c)
+    line, err := r.ReadString('\n')
+    if err != nil {
+        t.Fatal(err)
+    }
+    if line != "177777\n" {
+        t.Errorf("have: %q, want: %q", line, "177777\n")
+    }
+
+    // Closing the listener disconnects the client and stops reporting
+    // outputs to it
+    l.Close()
+    if _, err := r.ReadString('\n'); err == nil {
+        t.Error("have: nil, want: err")
+    }
+    waitFor(t, func() bool {
+        p.mu.Lock()
+        defer p.mu.Unlock()
+        return len(p.outputs) == 0
+    })
+}
+
+func TestDIOT(t *testing.T) {
+    n := NewNova()
+    p := NewDigitalPort()
+    err := n.Attach(DevDIO, p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    p.SetInput(0123)
+
+    // Inputs sampled after 1ms
+    d := n.devices[DevDIOT]
+    d.write(ioDOA, ioS, 1)
+    if !d.test(ioBN) {
+        t.Error("busy: have: false, want: true")
+    }
+    waitDone(t, d)
+    if in := d.read(ioDIA, ioC); in != 0123 {
+        t.Errorf("have: %06o, want: %06o", in, 0123)
+    }
+
+    // Clearing Busy stops the timer
+    d.write(ioDOA, ioS, 0)
+    d.write(ioNIO, ioC, 0)
+    if d.test(ioBN) || d.test(ioDN) {
+        t.Error("busy or done: have: true, want: false")
+    }
+}
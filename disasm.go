@@ -134,8 +134,8 @@ var ioD = [...]string{
     022: "MTA",
     023: "DACV",
     024: "DCM",
-    025: "25",
-    026: "26",
+    025: "CDP",
+    026: "PIT",
     027: "27",
     030: "QTY",
     031: "IBM1",
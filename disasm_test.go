@@ -0,0 +1,61 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "testing"
+)
+
+func TestDisasmDevice(t *testing.T) {
+    tests := []struct{
+        ir uint16
+        want string
+    }{
+        {0060416, "DIA     0,CDR"},
+        {0065125, "DOAS    1,CDP"},
+        {0063425, "SKPBN   CDP"},
+        {0060225, "NIOC    CDP"},
+        {0062677, "IORST   "},
+    }
+    for _, test := range tests {
+        if have := DisasmWord(test.ir); have != test.want {
+            t.Errorf("%06o: have: %q, want: %q", test.ir, have, test.want)
+        }
+    }
+
+    // Mnemonics match the device codes
+    codes := map[uint16]string{
+        DevPTR: "PTR",
+        DevPLT: "PLT",
+        DevCDR: "CDR",
+        DevCDP: "CDP",
+        DevQTY: "QTY",
+        DevCRC: "CRC",
+        DevIPB: "IPB",
+    }
+    for code, name := range codes {
+        if have := ioD[code]; have != name {
+            t.Errorf("%03o: have: %s, want: %s", code, have, name)
+        }
+    }
+}
@@ -23,8 +23,10 @@
 package nova
 
 import (
+    "io"
     "time"
     "bytes"
+    "sync/atomic"
     "math/rand"
     "math/bits"
 
@@ -1801,12 +1803,16 @@ The comments below match the labels in the program listing of the document above
         media *bytes.Buffer
     }
 
-    // Input device test setup
-    inputTests := [...]devTest{
-        {DevTTI, newTestTape()},
-        {DevPTR, newTestTape()},
-        {DevTTI1, newTestTape()},
-        {DevPTR1, newTestTape()},
+    // Input device test setup. The tapes are read through counters, as a
+    // read may be in progress when the media is detached.
+    inputTests := [...]struct {
+        dev int
+        media *countingReader
+    }{
+        {DevTTI, &countingReader{r: newTestTape()}},
+        {DevPTR, &countingReader{r: newTestTape()}},
+        {DevTTI1, &countingReader{r: newTestTape()}},
+        {DevPTR1, &countingReader{r: newTestTape()}},
     }
     for _, test := range inputTests {
         err := n.Attach(test.dev, test.media)
@@ -1815,7 +1821,6 @@ The comments below match the labels in the program listing of the document above
             return
         }
     }
-    startInputMediaLen := inputTests[0].media.Len()
 
     // Output device test setup
     outputTests := [...]devTest{
@@ -1855,10 +1860,18 @@ The comments below match the labels in the program listing of the document above
         n.Continue()
     }
 
+    // Unload the media, flushing the output
+    for _, test := range inputTests {
+        n.Detach(test.dev)
+    }
+    for _, test := range outputTests {
+        n.Detach(test.dev)
+    }
+
     // Check that input was consumed
     for _, test := range inputTests {
-        if !(test.media.Len() < startInputMediaLen) {
-            t.Errorf("%s: have: %d, want: <%d", deviceName(uint16(test.dev)), test.media.Len(), startInputMediaLen)
+        if test.media.n.Load() == 0 {
+            t.Errorf("%s: have: 0, want: >0", deviceName(uint16(test.dev)))
         }
     }
     // Check that output was produced
@@ -1869,6 +1882,18 @@ The comments below match the labels in the program listing of the document above
     }
 }
 
+// countingReader counts the bytes read from r.
+type countingReader struct {
+    r io.Reader
+    n atomic.Int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+    n, err := c.r.Read(b)
+    c.n.Add(int64(n))
+    return n, err
+}
+
 func newTestTape() *bytes.Buffer {
     var b bytes.Buffer
 
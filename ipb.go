@@ -0,0 +1,324 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "net"
+    "time"
+)
+
+// IPB status bits returned by DIB.
+const (
+    ipbWord     = 0100000   // Word received
+    ipbSignal   = 0040000   // Interrupt signalled by other processor
+    ipbWatchdog = 0020000   // Other processor failed to stroke watchdog
+    ipbBusy     = 0010000   // Transmitted word not yet accepted
+    ipbLinkDown = 0004000   // No link to other processor
+)
+
+// IPB link frame types. Each frame is a type byte followed by a 16-bit word,
+// high byte first.
+const (
+    ipbFrameWord = iota + 1 // Data word
+    ipbFrameAck             // Data word accepted
+    ipbFrameSignal          // Interrupt request
+    ipbFrameStroke          // Watchdog stroke
+)
+
+// ipbFrame is a frame received from the other processor, or a report that the
+// link has failed.
+type ipbFrame struct {
+    typ byte
+    data uint16
+    gen int             // Generation of the link the frame was received on
+    failed bool         // Link failed; no frame was received
+}
+
+// ipb emulates an inter-processor buffer linking two processors. DOA
+// transmits a word to the other processor; Busy is set until the other
+// processor reads it with DIA. Done is set, and an interrupt requested, when a
+// word is received, when the other processor signals with DOB, when the
+// watchdog expires and when the link fails. DIB returns the status. DOC loads
+// the watchdog timeout in milliseconds, 0 disabling the watchdog, and the
+// other processor must stroke the watchdog within each timeout period using
+// NIOP. Clearing Done clears the signal, watchdog and link conditions.
+type ipb struct {
+    controller
+    link io.ReadWriter
+    out chan []byte         // Frames to transmit
+    frames chan ipbFrame    // Frames received
+    links chan io.ReadWriter
+    gen int                 // Link generation, incremented on each attach
+    status uint16
+    failed bool             // Link has failed
+    word uint16             // Received word
+    timeout time.Duration   // Watchdog timeout
+}
+
+// newIPB creates an inter-processor buffer with no link attached.
+func newIPB(n *Nova, num, pri uint16) *ipb {
+    d := &ipb{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        frames: make(chan ipbFrame),
+        links: make(chan io.ReadWriter),
+    }
+    go d.device()
+    return d
+}
+
+func (d *ipb) device() {
+    watchdog := time.NewTimer(time.Second)
+    watchdog.Stop()
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                stopTimer(watchdog)
+                d.timeout = 0
+                if d.status&ipbWord != 0 {
+                    d.send(ipbFrameAck, 0)
+                }
+                d.status = 0
+                d.failed = false
+            case ioDIA:
+                msg.data = d.word
+                if d.status&ipbWord != 0 {
+                    d.status &^= ipbWord
+                    d.send(ipbFrameAck, 0)
+                }
+            case ioDIB:
+                msg.data = d.status
+                if d.link == nil {
+                    msg.data |= ipbLinkDown
+                }
+            case ioDOA:
+                if d.link != nil {
+                    d.status |= ipbBusy
+                    d.send(ipbFrameWord, msg.data)
+                }
+            case ioDOB:
+                if msg.data&1 != 0 {
+                    d.send(ipbFrameSignal, 0)
+                }
+            case ioDOC:
+                d.timeout = time.Duration(msg.data)*time.Millisecond
+                stopTimer(watchdog)
+                if d.timeout != 0 {
+                    watchdog.Reset(d.timeout)
+                }
+            case ioNIO:
+            case ioSKP:
+                msg.data = skipFlags(msg, d.status&ipbBusy != 0, d.state == devDone)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            if msg.typ != ioSKP {
+                switch msg.flags {
+                case ioC:
+                    d.status &^= ipbSignal | ipbWatchdog
+                    d.failed = false
+                case ioP:
+                    d.send(ipbFrameStroke, 0)
+                }
+            }
+            d.update()
+            d.dev <- msg    // Ack
+        case f := <-d.frames:
+            if f.gen != d.gen {
+                // Frame from replaced link
+                break
+            }
+            if f.failed {
+                d.link = nil
+                d.failed = true
+                d.status &^= ipbBusy
+                d.update()
+                break
+            }
+            switch f.typ {
+            case ipbFrameWord:
+                d.word = f.data
+                d.status |= ipbWord
+            case ipbFrameAck:
+                d.status &^= ipbBusy
+            case ipbFrameSignal:
+                d.status |= ipbSignal
+            case ipbFrameStroke:
+                if d.timeout != 0 {
+                    stopTimer(watchdog)
+                    watchdog.Reset(d.timeout)
+                }
+            }
+            d.update()
+        case <-watchdog.C:
+            d.status |= ipbWatchdog
+            d.update()
+        case link := <-d.links:
+            d.attachLink(link)
+            d.update()
+        }
+    }
+}
+
+// update sets Done if a word, signal, watchdog or link failure is pending.
+func (d *ipb) update() {
+    if d.status&(ipbWord|ipbSignal|ipbWatchdog) != 0 || d.failed {
+        if d.state != devDone {
+            d.state = devDone
+            d.n.setInt(d.num)
+        }
+        return
+    }
+    d.idle()
+}
+
+// snapshot returns the Busy and Done flags and the received word. Busy is set
+// while a transmitted word has not been accepted.
+func (d *ipb) snapshot() (busy, done bool, data uint16) {
+    d.sync(func() {
+        busy, done, data = d.status&ipbBusy != 0, d.state == devDone, d.word
+    })
+    return busy, done, data
+}
+
+// send queues a frame for transmission. The frame is lost if the link has
+// failed.
+func (d *ipb) send(typ byte, data uint16) {
+    if d.link == nil {
+        return
+    }
+    select {
+    case d.out <- []byte{typ, byte(data >> 8), byte(data)}:
+    default:
+    }
+}
+
+// attachLink replaces the link to the other processor. A nil link detaches
+// the link.
+func (d *ipb) attachLink(link io.ReadWriter) {
+    if d.out != nil {
+        close(d.out)
+        d.out = nil
+    }
+    d.link = link
+    d.gen++
+    d.failed = false
+    d.status &^= ipbWord | ipbBusy
+    if link == nil {
+        return
+    }
+    d.out = make(chan []byte, 16)
+    go d.writer(link, d.out)
+    go d.reader(link, d.gen)
+}
+
+// reader receives frames from the link with generation gen until it fails.
+func (d *ipb) reader(link io.Reader, gen int) {
+    b := make([]byte, 3)
+    for {
+        if _, err := io.ReadFull(link, b); err != nil {
+            d.frames <- ipbFrame{gen: gen, failed: true}
+            return
+        }
+        d.frames <- ipbFrame{typ: b[0], data: uint16(b[1]) << 8 | uint16(b[2]), gen: gen}
+    }
+}
+
+// writer transmits queued frames on the link.
+func (d *ipb) writer(link io.Writer, out chan []byte) {
+    for frame := range out {
+        if _, err := link.Write(frame); err != nil {
+            // Reader reports the failure
+            for range out {
+            }
+            return
+        }
+    }
+}
+
+// attachMedia attaches the link to the other processor. A nil media detaches
+// the link; the caller is responsible for closing the old link.
+func (d *ipb) attachMedia(media interface{}) error {
+    link, ok := media.(io.ReadWriter)
+    if !ok && media != nil {
+        return fmt.Errorf("%s: need io.ReadWriter media", deviceName(d.num))
+    }
+    d.links <- link
+    return nil
+}
+
+// ConnectIPB links the inter-processor buffer of n to that of other, a
+// processor in the same process.
+func (n *Nova) ConnectIPB(other *Nova) error {
+    c1, c2 := net.Pipe()
+    if err := n.Attach(DevIPB, c1); err != nil {
+        return err
+    }
+    return other.Attach(DevIPB, c2)
+}
+
+// ListenIPB listens on the local network address and links the
+// inter-processor buffer of n to the first processor that connects using
+// DialIPB. The listener is closed once the link is made. The processor may be
+// running when the connection is made.
+func (n *Nova) ListenIPB(network, address string) (net.Listener, error) {
+    l, err := net.Listen(network, address)
+    if err != nil {
+        return nil, err
+    }
+    go func() {
+        defer l.Close()
+        c, err := l.Accept()
+        if err != nil {
+            return
+        }
+        if err := n.Attach(DevIPB, c); err != nil {
+            c.Close()
+        }
+    }()
+    return l, nil
+}
+
+// DialIPB connects to a processor listening with ListenIPB and links it to the
+// inter-processor buffer of n.
+func (n *Nova) DialIPB(network, address string) error {
+    c, err := net.Dial(network, address)
+    if err != nil {
+        return err
+    }
+    if err := n.Attach(DevIPB, c); err != nil {
+        c.Close()
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,138 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "net"
+    "path/filepath"
+    "time"
+
+    "testing"
+)
+
+func TestIPB(t *testing.T) {
+    a, b := NewNova(), NewNova()
+    err := a.ConnectIPB(b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    da, db := a.devices[DevIPB], b.devices[DevIPB]
+
+    // Word transfer
+    da.write(ioDOA, 0, 0123456)
+    if !da.test(ioBN) {
+        t.Error("busy: have: false, want: true")
+    }
+    waitDone(t, db)
+    if status := db.read(ioDIB, 0); status != ipbWord {
+        t.Errorf("status: have: %06o, want: %06o", status, ipbWord)
+    }
+    if word := db.read(ioDIA, 0); word != 0123456 {
+        t.Errorf("word: have: %06o, want: %06o", word, 0123456)
+    }
+    waitFor(t, func() bool { return da.test(ioBZ) })
+    if db.test(ioDN) {
+        t.Error("done: have: true, want: false")
+    }
+
+    // Interrupt signal
+    db.write(ioDOB, 0, 1)
+    waitDone(t, da)
+    if status := da.read(ioDIB, ioC); status != ipbSignal {
+        t.Errorf("status: have: %06o, want: %06o", status, ipbSignal)
+    }
+
+    // Watchdog kept alive by strokes for several timeouts, then expires. Each
+    // stroke is followed by a signal, which arrives after the stroke is seen.
+    da.write(ioDOC, 0, 100)
+    end := time.Now().Add(time.Millisecond*300)
+    for time.Now().Before(end) {
+        db.write(ioNIO, ioP, 0)
+        db.write(ioDOB, 0, 1)
+        waitDone(t, da)
+        if status := da.read(ioDIB, ioC); status != ipbSignal {
+            t.Fatalf("status: have: %06o, want: %06o", status, ipbSignal)
+        }
+    }
+    waitDone(t, da)
+    if status := da.read(ioDIB, ioC); status != ipbWatchdog {
+        t.Errorf("status: have: %06o, want: %06o", status, ipbWatchdog)
+    }
+}
+
+func TestIPBSocket(t *testing.T) {
+    a, b := NewNova(), NewNova()
+    path := filepath.Join(t.TempDir(), "ipb")
+    _, err := a.ListenIPB("unix", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    err = b.DialIPB("unix", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    da, db := a.devices[DevIPB], b.devices[DevIPB]
+    waitFor(t, func() bool { return da.read(ioDIB, 0)&ipbLinkDown == 0 })
+    db.write(ioDOA, 0, 7)
+    waitDone(t, da)
+    if word := da.read(ioDIA, 0); word != 7 {
+        t.Errorf("word: have: %06o, want: %06o", word, 7)
+    }
+
+    // Both connections are recorded
+    for _, n := range []*Nova{a, b} {
+        waitFor(t, func() bool {
+            list := n.Attachments()
+            return len(list) == 1 && list[0].Code == DevIPB
+        })
+    }
+}
+
+func TestIPBFrames(t *testing.T) {
+    n := NewNova()
+    c1, c2 := net.Pipe()
+    defer c2.Close()
+    err := n.Attach(DevIPB, c1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    d := n.devices[DevIPB]
+    waitFor(t, func() bool { return d.read(ioDIB, 0)&ipbLinkDown == 0 })
+
+    // Only strokes keep the watchdog alive
+    d.write(ioDOC, 0, 20)
+    waitFor(t, func() bool {
+        c2.Write([]byte{ipbFrameSignal, 0, 0})
+        waitFor(t, func() bool { return d.read(ioDIB, 0)&ipbSignal != 0 })
+        return d.read(ioDIB, ioC)&ipbWatchdog != 0
+    })
+
+    // A frame of unknown type from the peer does not fail the link
+    c2.Write([]byte{0, 0, 0})
+    c2.Write([]byte{ipbFrameWord, 0, 5})
+    waitDone(t, d)
+    if status := d.read(ioDIB, 0); status&(ipbLinkDown|ipbWord) != ipbWord {
+        t.Errorf("status: have: %06o, want: %06o", status, ipbWord)
+    }
+}
@@ -181,7 +181,10 @@ func (n *Nova) LoadBinaryLoader() int {
 // on the second word of the start block. Client programs should check if the
 // processor is running after calling this function. If the program is halted,
 // the program should be started at the address specified by the program
-// documentation.
+// documentation. If the processor is still running after loading from the
+// paper tape reader, ReaderPosition reports whether the tape ran out before the
+// start block was read. Setting the rate of the device to Unthrottled with
+// SetRate loads the tape without waiting for the reader.
 func (n *Nova) LoadAbsoluteBinary(dev int, media io.Reader) error {
     switch dev {
     case DevTTI:
@@ -191,7 +194,10 @@ func (n *Nova) LoadAbsoluteBinary(dev int, media io.Reader) error {
     default:
         return fmt.Errorf("invalid input device: %s", deviceName(uint16(dev)))
     }
-    n.Attach(dev, media)
+    err := n.Attach(dev, media)
+    if err != nil {
+        return err
+    }
 
     startAddr := n.LoadBinaryLoader()
     n.Start(startAddr)
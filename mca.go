@@ -0,0 +1,369 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "fmt"
+    "sync"
+    "time"
+    "errors"
+)
+
+// MCA transmitter status bits returned by DIA.
+const (
+    mcatTimeout = 0100000   // Receiver did not accept block in time
+    mcatNoUnit  = 0040000   // Destination unit not on bus
+)
+
+// MCA receiver status bit returned by DIA. The source unit is returned in
+// bits 12-15.
+const (
+    mcarOverflow = 0100000  // Block truncated to word count
+)
+
+// Maximum MCA unit number.
+const kMCAUnits = 15
+
+// Errors returned by MCABus.deliver.
+var (
+    errMCATimeout = errors.New("timeout")
+    errMCANoUnit  = errors.New("no unit")
+)
+
+// MCABus is a simulated bus shared by the multiprocessor communications
+// adapters of several processors. Timeout is how long a transmitter waits for
+// the destination receiver to accept a block.
+type MCABus struct {
+    Timeout time.Duration
+
+    mu sync.Mutex
+    units map[int]*mcar
+}
+
+// NewMCABus creates a bus with a transmit timeout of 100ms.
+func NewMCABus() *MCABus {
+    return &MCABus{
+        Timeout: time.Millisecond*100,
+        units: make(map[int]*mcar),
+    }
+}
+
+// Connect connects the adapter of processor n to the bus as the specified
+// unit, 1 to 15. The adapter may be connected while the processor is running,
+// and an adapter already connected as another unit is moved to the new unit.
+// An error is returned if the unit is already connected. Detach DevMCAT and
+// DevMCAR to disconnect the adapter.
+func (b *MCABus) Connect(n *Nova, unit int) error {
+    if unit < 1 || unit > kMCAUnits {
+        return fmt.Errorf("invalid MCA unit: %d", unit)
+    }
+    r := n.devices[DevMCAR].(*mcar)
+    b.mu.Lock()
+    _, used := b.units[unit]
+    if !used {
+        b.units[unit] = r
+    }
+    b.mu.Unlock()
+    if used {
+        return fmt.Errorf("MCA unit %d already connected", unit)
+    }
+
+    port := &mcaPort{b, unit}
+    err := n.Attach(DevMCAT, port)
+    if err == nil {
+        err = n.Attach(DevMCAR, port)
+    }
+    if err != nil {
+        b.disconnect(unit, r)
+    }
+    return err
+}
+
+// disconnect removes receiver r from the bus if it is connected as unit.
+func (b *MCABus) disconnect(unit int, r *mcar) {
+    b.mu.Lock()
+    if b.units[unit] == r {
+        delete(b.units, unit)
+    }
+    b.mu.Unlock()
+}
+
+// deliver delivers a block to the receiver of unit dst. It returns once the
+// receiver has accepted the block, or with an error if the unit is not on the
+// bus or the receiver is not enabled within the timeout period.
+func (b *MCABus) deliver(src, dst int, words []uint16) error {
+    b.mu.Lock()
+    r := b.units[dst]
+    timeout := b.Timeout
+    b.mu.Unlock()
+    if r == nil {
+        return errMCANoUnit
+    }
+
+    blk := mcaBlock{src, words}
+    select {
+    case r.inbox <- blk:
+        return nil
+    case <-time.After(timeout):
+        return errMCATimeout
+    }
+}
+
+// mcaPort connects an adapter to a bus.
+type mcaPort struct {
+    bus *MCABus
+    unit int
+}
+
+// mcaBlock is a block sent over the bus.
+type mcaBlock struct {
+    src int
+    words []uint16
+}
+
+// mcat emulates the transmitter of a multiprocessor communications adapter.
+// DOA loads the memory address, DOB the word count and DOC the destination
+// unit in bits 12-15. Setting Busy transmits the block, which is loaded from
+// memory by data channel. Done is set when the destination receiver has
+// accepted the block or the transmission has failed. DIA returns the status.
+type mcat struct {
+    controller
+    port *mcaPort
+    addr uint16
+    count uint16
+    dst int
+    status uint16
+    buf []uint16
+    xfer <-chan struct{}    // Pending data channel transfer
+    gen int                 // Transmission generation
+    result chan mcaResult   // Delivery result
+}
+
+// mcaResult is the result of delivering the block sent by transmission gen.
+type mcaResult struct {
+    gen int
+    err error
+}
+
+// newMCAT creates an adapter transmitter that is not connected to a bus.
+func newMCAT(n *Nova, num, pri uint16) *mcat {
+    d := &mcat{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        result: make(chan mcaResult, 1),
+    }
+    go d.device()
+    return d
+}
+
+func (d *mcat) device() {
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                // Results of transmissions in progress are dropped
+                d.status = 0
+                d.xfer = nil
+                d.gen++
+                d.idle()
+            case ioDIA:
+                msg.data = d.status
+                d.transmit(msg)
+            case ioDOA:
+                d.addr = msg.data&kAddrMask
+                d.transmit(msg)
+            case ioDOB:
+                d.count = msg.data
+                d.transmit(msg)
+            case ioDOC:
+                d.dst = int(msg.data&017)
+                d.transmit(msg)
+            case ioNIO, ioDIB, ioDIC:
+                d.transmit(msg)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-d.xfer:
+            // Block loaded from memory; send it
+            d.xfer = nil
+            port, dst, words, gen := d.port, d.dst, d.buf, d.gen
+            go func() {
+                d.result <- mcaResult{gen, port.bus.deliver(port.unit, dst, words)}
+            }()
+        case r := <-d.result:
+            if r.gen != d.gen {
+                break
+            }
+            switch r.err {
+            case errMCATimeout:
+                d.status = mcatTimeout
+            case errMCANoUnit:
+                d.status = mcatNoUnit
+            }
+            d.complete()
+        }
+    }
+}
+
+// transmit sets the transmitter state from the message flags, starting a
+// transmission if Busy is set.
+func (d *mcat) transmit(msg devmsg) {
+    if msg.flags == ioS && d.state == devBusy {
+        // Transmission in progress
+        return
+    }
+    d.flags(msg)
+    if msg.flags != ioS {
+        return
+    }
+    d.status = 0
+    if d.port == nil {
+        d.status = mcatNoUnit
+        d.complete()
+        return
+    }
+    d.buf = make([]uint16, d.count)
+    d.xfer = d.n.requestTransfer(d.addr, d.buf, false)
+}
+
+// attachMedia connects the transmitter to a bus. A nil media disconnects it.
+func (d *mcat) attachMedia(media interface{}) error {
+    port, ok := media.(*mcaPort)
+    if !ok && media != nil {
+        return fmt.Errorf("%s: connect using MCABus", deviceName(d.num))
+    }
+    d.sync(func() {
+        d.port = port
+    })
+    return nil
+}
+
+// mcar emulates the receiver of a multiprocessor communications adapter. DOA
+// loads the memory address and DOB the maximum word count. Setting Busy
+// enables the receiver. Done is set when a block has been received and stored
+// in memory by data channel. DIA returns the status and source unit, and DIB
+// returns the number of words received.
+type mcar struct {
+    controller
+    port *mcaPort
+    addr uint16
+    count uint16
+    length uint16           // Words received
+    status uint16
+    inbox chan mcaBlock     // Blocks from transmitters
+    xfer <-chan struct{}    // Pending data channel transfer
+}
+
+// newMCAR creates an adapter receiver that is not connected to a bus.
+func newMCAR(n *Nova, num, pri uint16) *mcar {
+    d := &mcar{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        inbox: make(chan mcaBlock),
+    }
+    go d.device()
+    return d
+}
+
+func (d *mcar) device() {
+    for {
+        // Accept blocks only while enabled
+        var inbox chan mcaBlock
+        if d.state == devBusy && d.xfer == nil {
+            inbox = d.inbox
+        }
+
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                d.status = 0
+                d.xfer = nil
+                d.idle()
+            case ioDIA:
+                msg.data = d.status
+                d.flags(msg)
+            case ioDIB:
+                msg.data = d.length
+                d.flags(msg)
+            case ioDOA:
+                d.addr = msg.data&kAddrMask
+                d.flags(msg)
+            case ioDOB:
+                d.count = msg.data
+                d.flags(msg)
+            case ioNIO, ioDIC, ioDOC:
+                d.flags(msg)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case blk := <-inbox:
+            words := blk.words
+            d.status = uint16(blk.src)
+            if len(words) > int(d.count) {
+                words = words[:d.count]
+                d.status |= mcarOverflow
+            }
+            d.length = uint16(len(words))
+            d.xfer = d.n.requestTransfer(d.addr, words, true)
+        case <-d.xfer:
+            d.xfer = nil
+            d.complete()
+        }
+    }
+}
+
+// attachMedia connects the receiver to a bus. A nil media disconnects it so
+// that blocks sent to its unit are no longer accepted.
+func (d *mcar) attachMedia(media interface{}) error {
+    port, ok := media.(*mcaPort)
+    if !ok && media != nil {
+        return fmt.Errorf("%s: connect using MCABus", deviceName(d.num))
+    }
+    d.sync(func() {
+        if d.port != nil && d.port != port {
+            d.port.bus.disconnect(d.port.unit, d)
+        }
+        d.port = port
+    })
+    return nil
+}
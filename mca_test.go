@@ -0,0 +1,136 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "time"
+
+    "testing"
+)
+
+func TestMCA(t *testing.T) {
+    bus := NewMCABus()
+    bus.Timeout = time.Millisecond*20
+    a, b, c := NewNova(), NewNova(), NewNova()
+    for unit, n := range []*Nova{a, b, c} {
+        err := bus.Connect(n, unit + 1)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := bus.Connect(NewNova(), 1); err == nil {
+        t.Error("have: nil, want: err")
+    }
+
+    // Enable receiver of unit 2 with room for 2 words
+    rb := b.devices[DevMCAR]
+    rb.write(ioDOA, 0, 0200)
+    rb.write(ioDOB, ioS, 2)
+
+    a.LoadMemory(0100, []uint16{011, 022, 033})
+    ta := a.devices[DevMCAT]
+    send := func(dst, count uint16) uint16 {
+        ta.write(ioDOA, 0, 0100)
+        ta.write(ioDOB, 0, count)
+        ta.write(ioDOC, ioS, dst)
+        waitDone(t, ta)
+        return ta.read(ioDIA, ioC)
+    }
+
+    if status := send(2, 3); status != 0 {
+        t.Errorf("status: have: %06o, want: 0", status)
+    }
+    waitDone(t, rb)
+    if status := rb.read(ioDIA, 0); status != mcarOverflow|1 {
+        t.Errorf("status: have: %06o, want: %06o", status, mcarOverflow|1)
+    }
+    if length := rb.read(ioDIB, ioC); length != 2 {
+        t.Errorf("length: have: %d, want: 2", length)
+    }
+    for i, want := range []int{011, 022, 0} {
+        have, _ := b.Examine(0200 + i)
+        if have != want {
+            t.Errorf("%05o: have: %06o, want: %06o", 0200 + i, have, want)
+        }
+    }
+
+    // Receiver of unit 3 not enabled
+    if status := send(3, 1); status != mcatTimeout {
+        t.Errorf("status: have: %06o, want: %06o", status, mcatTimeout)
+    }
+    if status := send(9, 1); status != mcatNoUnit {
+        t.Errorf("status: have: %06o, want: %06o", status, mcatNoUnit)
+    }
+
+    // Moving an adapter to another unit frees its unit
+    if err := bus.Connect(c, 4); err != nil {
+        t.Fatal(err)
+    }
+    if err := bus.Connect(NewNova(), 3); err != nil {
+        t.Errorf("have: %v, want: nil", err)
+    }
+}
+
+func TestMCAReset(t *testing.T) {
+    bus := NewMCABus()
+    bus.Timeout = time.Millisecond*20
+    a, b, c := NewNova(), NewNova(), NewNova()
+    for unit, n := range []*Nova{a, b, c} {
+        bus.Connect(n, unit + 1)
+    }
+
+    // Receiver of unit 3 not enabled, so the delivery waits for the timeout
+    ta := a.devices[DevMCAT].(*mcat)
+    ta.write(ioDOB, 0, 1)
+    ta.write(ioDOC, ioS, 3)
+    waitFor(t, func() bool {
+        var sent bool
+        ta.sync(func() {
+            sent = ta.xfer == nil
+        })
+        return sent
+    })
+    ta.write(ioRST, 0, 0)
+
+    // The late result of the reset transmission does not affect the next
+    rb := b.devices[DevMCAR]
+    rb.write(ioDOB, ioS, 1)
+    ta.write(ioDOC, ioS, 2)
+    waitDone(t, ta)
+
+    // A delivery started later times out after the reset transmission, whose
+    // result is then queued or already dropped
+    if err := bus.deliver(1, 3, nil); err != errMCATimeout {
+        t.Fatalf("have: %v, want: %v", err, errMCATimeout)
+    }
+    waitFor(t, func() bool {
+        var queued int
+        ta.sync(func() {
+            queued = len(ta.result)
+        })
+        return queued == 0
+    })
+    if status := ta.read(ioDIA, 0); status != 0 {
+        t.Errorf("status: have: %06o, want: 0", status)
+    }
+}
@@ -0,0 +1,224 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "time"
+)
+
+// Multiplexer line event types.
+const (
+    muxRecv = iota  // Character received
+    muxXmit         // Character transmitted
+    muxDrop         // Character lost by a failed write
+)
+
+// muxEvent is sent to a multiplexer device goroutine by its line goroutines.
+type muxEvent struct {
+    typ int
+    line int
+    gen int     // Line attachment generation
+    char byte
+    err error   // Write error of muxDrop
+}
+
+// muxLine is the state of a multiplexer line. Each attachment of media to a
+// line starts a reader goroutine, which delivers one received character at a
+// time, and a writer goroutine, which transmits characters at the line rate.
+// Events from a previous attachment are discarded. A line whose media fails a
+// write is detached.
+type muxLine struct {
+    rw io.ReadWriter
+    gen int
+    tx chan muxTx       // Characters to transmit
+    ack chan struct{}   // Received character accepted
+    quit chan struct{}  // Attachment replaced
+    done chan struct{}  // Closed when the reader returns
+    char byte           // Received character
+    recv bool           // Received character available
+    xmit bool           // Transmitter ready
+}
+
+// mux holds the lines of a multiplexer device.
+type mux struct {
+    lines []muxLine
+    period time.Duration        // Character transmission time
+    events chan muxEvent
+}
+
+// muxTx is a character to transmit and its transmission time.
+type muxTx struct {
+    c byte
+    period time.Duration
+}
+
+// newMux creates a multiplexer with count lines transmitting rate characters
+// per second. No media is attached to the lines.
+func newMux(count int, rate float32) *mux {
+    m := &mux{
+        lines: make([]muxLine, count),
+        period: time.Duration(float32(time.Second)/rate),
+        events: make(chan muxEvent),
+    }
+    for i := range m.lines {
+        m.attach(i, nil)
+    }
+    return m
+}
+
+// attach attaches media to a line, replacing any existing media. It must be
+// called by the device goroutine.
+func (m *mux) attach(line int, rw io.ReadWriter) {
+    l := &m.lines[line]
+    if l.quit != nil {
+        close(l.quit)
+        close(l.tx)
+        l.stopReader()
+    }
+    l.rw = rw
+    l.gen++
+    l.tx = make(chan muxTx, 1)
+    l.ack = make(chan struct{}, 1)
+    l.quit = make(chan struct{})
+    l.done = nil
+    l.recv = false
+    l.xmit = false
+    go m.writer(line, l.gen, rw, l.tx)
+    if rw != nil {
+        l.done = make(chan struct{})
+        go m.reader(line, l.gen, rw, l.ack, l.quit, l.done)
+    }
+}
+
+// stopReader stops the reader of replaced media that supports read
+// deadlines, so that the media is not read by two goroutines if it is
+// attached again. The reader of other media returns after its next read.
+func (l *muxLine) stopReader() {
+    d, ok := l.rw.(interface{ SetReadDeadline(t time.Time) error })
+    if !ok || l.done == nil {
+        return
+    }
+    if d.SetReadDeadline(time.Now()) != nil {
+        return
+    }
+    <-l.done
+    d.SetReadDeadline(time.Time{})
+}
+
+// event applies a line event and returns the affected line. nil is returned
+// for events from a replaced attachment. A write error is reported with fail
+// and, unless the policy is ErrorIgnore, the line is detached.
+func (m *mux) event(e muxEvent, fail func(err error) int) *muxLine {
+    l := &m.lines[e.line]
+    if e.gen != l.gen {
+        return nil
+    }
+    switch e.typ {
+    case muxRecv:
+        l.char = e.char
+        l.recv = true
+    case muxXmit:
+        l.xmit = true
+    case muxDrop:
+        if fail(e.err) != ErrorIgnore {
+            m.attach(e.line, nil)
+        }
+        l.xmit = true
+    }
+    return l
+}
+
+// receive returns the received character of a line and accepts the next.
+func (m *mux) receive(line int) byte {
+    l := &m.lines[line]
+    if l.recv {
+        l.recv = false
+        l.ack <- struct{}{}
+    }
+    return l.char
+}
+
+// transmit sends c on a line. The character is lost if the transmitter is
+// still busy with the previous character.
+func (m *mux) transmit(line int, c byte) {
+    l := &m.lines[line]
+    l.xmit = false
+    select {
+    case l.tx <- muxTx{c, m.period}:
+    default:
+    }
+}
+
+// reset discards all received characters and transmitter ready conditions.
+func (m *mux) reset() {
+    for i := range m.lines {
+        m.receive(i)
+        m.lines[i].xmit = false
+    }
+}
+
+// reader delivers characters read from r one at a time, waiting for each to be
+// accepted before reading the next.
+func (m *mux) reader(line, gen int, r io.Reader, ack, quit, done chan struct{}) {
+    defer close(done)
+    b := make([]byte, 1)
+    for {
+        n, err := r.Read(b)
+        if err != nil {
+            // Line disconnected
+            return
+        }
+        if n == 0 {
+            continue
+        }
+        select {
+        case m.events <- muxEvent{muxRecv, line, gen, b[0], nil}:
+        case <-quit:
+            return
+        }
+        select {
+        case <-ack:
+        case <-quit:
+            return
+        }
+    }
+}
+
+// writer transmits characters to w, signalling when each character has been
+// sent. Characters are discarded if w is nil. A character that cannot be
+// written is lost, as it would be on a dropped line, and the error is passed
+// on with the signal.
+func (m *mux) writer(line, gen int, w io.Writer, tx chan muxTx) {
+    for t := range tx {
+        start := time.Now()
+        e := muxEvent{muxXmit, line, gen, 0, nil}
+        if w != nil {
+            if _, err := w.Write([]byte{t.c}); err != nil {
+                e.typ, e.err = muxDrop, err
+            }
+        }
+        time.Sleep(t.period - time.Since(start))
+        m.events <- e
+    }
+}
@@ -0,0 +1,152 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "bufio"
+    "fmt"
+    "sync"
+)
+
+// Paper tape geometry in millimetres. Frames are 0.1 inch apart and the eight
+// channels and the sprocket track are 0.1 inch apart across 1 inch tape.
+const (
+    kTapeWidth      = 25.4
+    kTapePitch      = 2.54
+    kTapeHole       = 1.83
+    kTapeSprocket   = 1.17
+)
+
+// tapeFrame renders the eight channels of the frame c as a line of ASCII art.
+// Channel 8 is on the left and the sprocket hole lies between channels 3 and
+// 4.
+func tapeFrame(c byte) string {
+    b := []byte("|     .   |")
+    for ch := 8; ch >= 1; ch-- {
+        if c&(1 << uint(ch - 1)) == 0 {
+            continue
+        }
+        i := 9 - ch
+        if ch <= 3 {
+            i++ // Skip sprocket
+        }
+        b[i] = 'o'
+    }
+    return string(b)
+}
+
+// tapeArt is a writer that renders each frame written to it as ASCII art.
+type tapeArt struct {
+    w io.Writer
+}
+
+// NewTapeArt returns a writer that renders the frames written to it as ASCII
+// art on w, one line per frame with the frame in octal and, if printable, as
+// an ASCII character. It may be combined with the punch media using
+// io.MultiWriter.
+func NewTapeArt(w io.Writer) io.Writer {
+    return &tapeArt{w: w}
+}
+
+func (t *tapeArt) Write(p []byte) (int, error) {
+    for i, c := range p {
+        line := fmt.Sprintf("%s %03o", tapeFrame(c), c)
+        if c7 := c&0177; c7 >= ' ' && c7 < 0177 {
+            line += fmt.Sprintf(" %c", c7)
+        }
+        if _, err := fmt.Fprintln(t.w, line); err != nil {
+            return i, err
+        }
+    }
+    return len(p), nil
+}
+
+// PaperTape is a writer that records the frames punched on a paper tape so
+// that the tape can be rendered when punching is finished.
+type PaperTape struct {
+    mu sync.Mutex
+    frames []byte
+}
+
+// NewPaperTape returns an empty paper tape.
+func NewPaperTape() *PaperTape {
+    return &PaperTape{}
+}
+
+// Write implements the io.Writer interface by punching the frames in p.
+func (t *PaperTape) Write(p []byte) (int, error) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.frames = append(t.frames, p...)
+    return len(p), nil
+}
+
+// Frames returns the frames punched so far.
+func (t *PaperTape) Frames() []byte {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    return append([]byte(nil), t.frames...)
+}
+
+// WriteASCII renders the tape as ASCII art on w.
+func (t *PaperTape) WriteASCII(w io.Writer) error {
+    b := bufio.NewWriter(w)
+    if _, err := NewTapeArt(b).Write(t.Frames()); err != nil {
+        return err
+    }
+    return b.Flush()
+}
+
+// WriteSVG renders the tape as an SVG document on w at full size. The tape
+// runs from left to right with channel 8 at the top.
+func (t *PaperTape) WriteSVG(w io.Writer) error {
+    frames := t.Frames()
+    length := float64(len(frames) + 2)*kTapePitch
+
+    b := bufio.NewWriter(w)
+    fmt.Fprintln(b, `<?xml version="1.0" encoding="UTF-8"?>`)
+    fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%gmm" height="%gmm" viewBox="0 0 %g %g">`+"\n",
+        length, kTapeWidth, length, kTapeWidth)
+    fmt.Fprintf(b, `<rect width="%g" height="%g" fill="#f5e9c8"/>`+"\n", length, kTapeWidth)
+    for i, c := range frames {
+        x := float64(i + 1)*kTapePitch + kTapePitch/2
+        for row := 1; row <= 9; row++ {
+            // Rows are numbered from the top; row 6 is the sprocket track
+            y := float64(row)*kTapePitch
+            if row == 6 {
+                fmt.Fprintf(b, `<circle cx="%.2f" cy="%.2f" r="%g"/>`+"\n", x, y, kTapeSprocket/2)
+                continue
+            }
+            ch := 9 - row
+            if row > 6 {
+                ch++
+            }
+            if c&(1 << uint(ch - 1)) != 0 {
+                fmt.Fprintf(b, `<circle cx="%.2f" cy="%.2f" r="%g"/>`+"\n", x, y, kTapeHole/2)
+            }
+        }
+    }
+    fmt.Fprintln(b, "</svg>")
+    return b.Flush()
+}
@@ -0,0 +1,66 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "io"
+    "strings"
+
+    "testing"
+)
+
+func TestPaperTape(t *testing.T) {
+    n := NewNova()
+    var art bytes.Buffer
+    tape := NewPaperTape()
+    err := n.Attach(DevPTP, io.MultiWriter(tape, NewTapeArt(&art)))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    d := n.devices[DevPTP]
+    for _, c := range []byte{0, 'A', 0377} {
+        d.write(ioDOA, ioS, uint16(c))
+        waitDone(t, d)
+    }
+
+    want := "|     .   | 000\n" +
+        "| o   .  o| 101 A\n" +
+        "|ooooo.ooo| 377\n"
+    if art.String() != want {
+        t.Errorf("have: %q, want: %q", art.String(), want)
+    }
+    if frames := tape.Frames(); !bytes.Equal(frames, []byte{0, 'A', 0377}) {
+        t.Errorf("frames: have: %v", frames)
+    }
+
+    var svg bytes.Buffer
+    if err := tape.WriteSVG(&svg); err != nil {
+        t.Fatal(err)
+    }
+    // Three sprocket holes and ten channel holes
+    if holes := strings.Count(svg.String(), "<circle"); holes != 13 {
+        t.Errorf("holes: have: %d, want: 13", holes)
+    }
+}
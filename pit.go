@@ -0,0 +1,141 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "time"
+)
+
+// pit emulates a programmable interval timer. DOA loads the interval register
+// with the two's complement of the number of counts in the interval. Setting
+// Busy loads the counter from the interval register and starts counting. When
+// the counter overflows, Done is set and the counter is reloaded from the
+// interval register; counting continues until Busy is cleared. DIA returns the
+// current value of the counter. An interval of 0 is 65536 counts.
+type pit struct {
+    controller
+    interval uint16     // Interval register
+    counting bool       // Busy
+    done bool
+    started time.Time   // Time counter was last loaded
+}
+
+// newPIT creates an interval timer that counts at rate counts per second.
+func newPIT(n *Nova, num, pri uint16, rate int) *pit {
+    d := &pit{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+    }
+    go d.device(rate)
+    return d
+}
+
+func (d *pit) device(rate int) {
+    count := time.Second/time.Duration(rate)
+    ticker := time.NewTicker(time.Second)
+    ticker.Stop()
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                stopTicker(ticker)
+                d.counting = false
+                d.interval = 0
+                d.clearDone()
+            case ioDIA:
+                if d.counting {
+                    elapsed := time.Since(d.started)%d.period(count)
+                    msg.data = d.interval + uint16(elapsed/count)
+                } else {
+                    msg.data = d.interval
+                }
+                d.pitFlags(msg, ticker, count)
+            case ioDOA:
+                d.interval = msg.data
+                d.pitFlags(msg, ticker, count)
+            case ioNIO, ioDIB, ioDOB, ioDIC, ioDOC:
+                d.pitFlags(msg, ticker, count)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case now := <-ticker.C:
+            // Counter overflow; reload
+            d.started = now
+            d.done = true
+            d.n.setInt(d.num)
+        }
+    }
+}
+
+// period returns the time taken to count through the interval.
+func (d *pit) period(count time.Duration) time.Duration {
+    counts := 0200000 - int(d.interval)
+    if d.interval == 0 {
+        counts = 0200000
+    }
+    return time.Duration(counts)*count
+}
+
+// pitFlags sets the timer state from the message flags.
+func (d *pit) pitFlags(msg devmsg, ticker *time.Ticker, count time.Duration) {
+    switch msg.flags {
+    case ioS:
+        d.clearDone()
+        d.counting = true
+        d.started = time.Now()
+        stopTicker(ticker)
+        ticker.Reset(d.period(count))
+    case ioC:
+        stopTicker(ticker)
+        d.counting = false
+        d.clearDone()
+    }
+}
+
+// skip returns skip condition specified by message flags.
+func (d *pit) skip(msg devmsg) uint16 {
+    return skipFlags(msg, d.counting, d.done)
+}
+
+// snapshot returns the Busy and Done flags and the interval register.
+func (d *pit) snapshot() (busy, done bool, data uint16) {
+    d.sync(func() {
+        busy, done, data = d.counting, d.done, d.interval
+    })
+    return busy, done, data
+}
+
+func (d *pit) clearDone() {
+    d.done = false
+    d.n.clearInt(d.num)
+}
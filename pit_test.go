@@ -0,0 +1,84 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "testing"
+)
+
+func TestPIT(t *testing.T) {
+    n := NewNova()
+    d := n.devices[devPIT]
+
+    // Interval of 100 counts (1ms)
+    interval := uint16(0200000 - 100)
+    d.write(ioDOA, ioS, interval)
+    if !d.test(ioBN) {
+        t.Error("busy: have: false, want: true")
+    }
+    count := d.read(ioDIA, 0)
+    if count < interval {
+        t.Errorf("counter: have: %06o, want: >=%06o", count, interval)
+    }
+
+    for i := 0; i < 3; i++ {
+        waitDone(t, d)
+
+        // Acknowledge and restart interval
+        d.write(ioNIO, ioS, 0)
+        if !d.test(ioBN) || d.test(ioDN) {
+            t.Error("have: not busy or done, want: busy")
+        }
+    }
+
+    d.write(ioNIO, ioC, 0)
+    if d.test(ioBN) || d.test(ioDN) {
+        t.Error("busy or done: have: true, want: false")
+    }
+}
+
+func TestRTC1(t *testing.T) {
+    n := NewNova()
+    d := n.devices[devRTC1]
+    d.write(ioDOA, ioS, 3)  // 1000 Hz
+    waitDone(t, d)
+}
+
+func TestPITRestart(t *testing.T) {
+    n := NewNova()
+    d := n.devices[devPIT]
+
+    // Interval of 1 count, which overflows again before the restart
+    d.write(ioDOA, ioS, 0177777)
+    waitDone(t, d)
+
+    // Restart with an interval of 65536 counts; an overflow of the previous
+    // interval must not set Done
+    d.write(ioDOA, ioS, 0)
+    for i := 0; i < 100; i++ {
+        if d.test(ioDN) {
+            t.Fatal("done: have: true, want: false")
+        }
+    }
+    d.write(ioNIO, ioC, 0)
+}
@@ -0,0 +1,274 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "time"
+    "io"
+    "fmt"
+    "sync"
+    "bufio"
+)
+
+// Plotter command bits loaded by DOA. A command may combine a pen operation
+// with X and Y steps; opposing steps on the same axis cancel.
+const (
+    PlotMinusY  = 0000001   // Step -Y
+    PlotPlusY   = 0000002   // Step +Y
+    PlotMinusX  = 0000004   // Step -X
+    PlotPlusX   = 0000010   // Step +X
+    PlotPenDown = 0000020   // Lower pen
+    PlotPenUp   = 0000040   // Raise pen
+)
+
+// A pen movement takes as long as this number of steps.
+const kPenSteps = 30
+
+// Point is a plotter position in steps from the origin.
+type Point struct {
+    X, Y int
+}
+
+// Plot is plotter media. It records the strokes drawn while the pen is down.
+// Step is the size of one plotter step in millimetres and Rate, if non-zero,
+// overrides the plotter speed in steps per second.
+type Plot struct {
+    Step float64
+    Rate float32
+
+    mu sync.Mutex
+    pos Point       // Pen position
+    down bool       // Pen is down
+    paths [][]Point // Strokes
+}
+
+// NewPlot returns an empty plot with the specified step size in millimetres.
+func NewPlot(step float64) *Plot {
+    return &Plot{Step: step}
+}
+
+// Paths returns the strokes drawn so far. Each stroke is the list of points
+// visited while the pen was down.
+func (p *Plot) Paths() [][]Point {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    paths := make([][]Point, len(p.paths))
+    for i, path := range p.paths {
+        paths[i] = append([]Point(nil), path...)
+    }
+    return paths
+}
+
+// WriteSVG renders the plot as an SVG document on w. The document is sized to
+// the extent of the strokes using the plot step size.
+func (p *Plot) WriteSVG(w io.Writer) error {
+    paths := p.Paths()
+    step := p.Step
+    if step <= 0 {
+        step = 0.1
+    }
+
+    var min, max Point
+    for i, path := range paths {
+        for j, pt := range path {
+            if i == 0 && j == 0 {
+                min, max = pt, pt
+            }
+            if pt.X < min.X {
+                min.X = pt.X
+            }
+            if pt.Y < min.Y {
+                min.Y = pt.Y
+            }
+            if pt.X > max.X {
+                max.X = pt.X
+            }
+            if pt.Y > max.Y {
+                max.Y = pt.Y
+            }
+        }
+    }
+    width := float64(max.X - min.X)*step
+    height := float64(max.Y - min.Y)*step
+
+    b := bufio.NewWriter(w)
+    fmt.Fprintln(b, `<?xml version="1.0" encoding="UTF-8"?>`)
+    fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%gmm" height="%gmm" viewBox="0 0 %g %g">`+"\n",
+        width, height, width, height)
+    for _, path := range paths {
+        b.WriteString(`<path fill="none" stroke="black" stroke-width="0.25" d="`)
+        for i, pt := range path {
+            // SVG Y axis points down the page
+            cmd := 'L'
+            if i == 0 {
+                cmd = 'M'
+            }
+            fmt.Fprintf(b, "%c%g %g", cmd, float64(pt.X - min.X)*step, float64(max.Y - pt.Y)*step)
+        }
+        b.WriteString("\"/>\n")
+    }
+    fmt.Fprintln(b, "</svg>")
+    return b.Flush()
+}
+
+// plot executes the plotter command cmd.
+func (p *Plot) plot(cmd uint16) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    switch cmd&(PlotPenUp|PlotPenDown) {
+    case PlotPenUp:
+        p.down = false
+    case PlotPenDown:
+        if !p.down {
+            p.down = true
+            p.paths = append(p.paths, []Point{p.pos})
+        }
+    }
+
+    moved := false
+    switch cmd&(PlotPlusX|PlotMinusX) {
+    case PlotPlusX:
+        p.pos.X++
+        moved = true
+    case PlotMinusX:
+        p.pos.X--
+        moved = true
+    }
+    switch cmd&(PlotPlusY|PlotMinusY) {
+    case PlotPlusY:
+        p.pos.Y++
+        moved = true
+    case PlotMinusY:
+        p.pos.Y--
+        moved = true
+    }
+
+    if moved && p.down {
+        last := len(p.paths) - 1
+        p.paths[last] = append(p.paths[last], p.pos)
+    }
+}
+
+// period returns the time taken to execute cmd if the plotter takes step to
+// move one step.
+func (p *Plot) period(cmd uint16, step time.Duration) time.Duration {
+    if p.Rate > 0 {
+        step = framePeriod(p.Rate)
+    }
+    if cmd&(PlotPenUp|PlotPenDown) != 0 {
+        return step*kPenSteps
+    }
+    return step
+}
+
+// plotter emulates an incremental plotter. DOA loads the command register and
+// setting Busy executes the command. Done is set when the command completes.
+type plotter struct {
+    controller
+    p *Plot
+    step time.Duration  // Time to move one step
+}
+
+// newPlotter creates a plotter that moves rate steps per second.
+func newPlotter(n *Nova, num, pri uint16, rate float32) *plotter {
+    d := &plotter{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        step: framePeriod(rate),
+    }
+    go d.device()
+    return d
+}
+
+func (d *plotter) device() {
+    t := time.NewTimer(time.Second * 1)
+    t.Stop()
+    expired := true
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                d.idle()
+            case ioDOA:
+                // Load command register
+                d.data = msg.data
+                fallthrough
+            case ioNIO, ioDIA, ioDIB, ioDOB, ioDIC, ioDOC:
+                if msg.flags == ioS {
+                    // Start device; delay until command completes
+                    if !t.Stop() && !expired {
+                        <-t.C
+                    }
+                    period := d.step
+                    if d.p != nil {
+                        period = d.p.period(d.data, d.step)
+                    }
+                    t.Reset(period)
+                    expired = false
+                }
+                d.flags(msg)
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.dev <- msg    // Ack
+        case <-t.C:
+            // Execute command
+            expired = true
+            if d.p != nil {
+                d.p.plot(d.data)
+            }
+            d.complete()
+        }
+    }
+}
+
+// setRate sets the time taken to move one step. The Rate of the attached Plot,
+// if set, still overrides it.
+func (d *plotter) setRate(period time.Duration) {
+    d.sync(func() {
+        d.step = period
+    })
+}
+
+// attachMedia attaches a Plot to the plotter. A nil media detaches the plot;
+// commands are then discarded.
+func (d *plotter) attachMedia(media interface{}) error {
+    p, ok := media.(*Plot)
+    if !ok && media != nil {
+        return fmt.Errorf("%s: need *Plot media", deviceName(d.num))
+    }
+    d.sync(func() {
+        d.p = p
+    })
+    return nil
+}
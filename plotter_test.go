@@ -0,0 +1,76 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "strings"
+
+    "testing"
+)
+
+func TestPlotter(t *testing.T) {
+    n := NewNova()
+    p := NewPlot(0.1)
+    p.Rate = 10000
+    err := n.Attach(DevPLT, p)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Draw a square with sides of 2 steps
+    commands := []uint16{
+        PlotPenDown,
+        PlotPlusX, PlotPlusX,
+        PlotPlusY, PlotPlusY,
+        PlotMinusX, PlotMinusX,
+        PlotMinusY|PlotPenUp,
+        PlotMinusY,
+    }
+    d := n.devices[DevPLT]
+    for _, cmd := range commands {
+        d.write(ioDOA, ioS, cmd)
+        waitDone(t, d)
+    }
+
+    paths := p.Paths()
+    want := []Point{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 2}}
+    if len(paths) != 1 || len(paths[0]) != len(want) {
+        t.Fatalf("have: %v, want: [%v]", paths, want)
+    }
+    for i, pt := range want {
+        if paths[0][i] != pt {
+            t.Errorf("point %d: have: %v, want: %v", i, paths[0][i], pt)
+        }
+    }
+
+    var b bytes.Buffer
+    err = p.WriteSVG(&b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    path := `d="M0 0.2L0.1 0.2L0.2 0.2L0.2 0.1L0.2 0L0.1 0L0 0"`
+    if !strings.Contains(b.String(), path) {
+        t.Errorf("have: %s, want: %s", b.String(), path)
+    }
+}
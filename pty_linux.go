@@ -0,0 +1,191 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "os"
+    "errors"
+    "fmt"
+    "syscall"
+    "unsafe"
+)
+
+// Pty is a host pseudo-terminal. The emulator reads and writes the master side
+// and host programs open the slave device returned by Name. The slave is put
+// into raw mode so that characters pass through unchanged.
+type Pty struct {
+    master *os.File
+    slave *os.File  // Held open so that the master does not see a hang up
+    name string
+}
+
+// OpenPty allocates a host pseudo-terminal.
+func OpenPty() (*Pty, error) {
+    master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+    if err != nil {
+        return nil, err
+    }
+
+    var num uint32
+    if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&num))); err != nil {
+        master.Close()
+        return nil, err
+    }
+    var unlock int32
+    if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+        master.Close()
+        return nil, err
+    }
+
+    name := fmt.Sprintf("/dev/pts/%d", num)
+    slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+    if err != nil {
+        master.Close()
+        return nil, err
+    }
+
+    p := &Pty{
+        master: master,
+        slave: slave,
+        name: name,
+    }
+    if err := p.rawMode(); err != nil {
+        p.Close()
+        return nil, err
+    }
+    return p, nil
+}
+
+// Name returns the path of the slave device.
+func (p *Pty) Name() string {
+    return p.name
+}
+
+// Read reads data written to the slave by host programs. io.EOF is returned
+// once the pseudo-terminal is closed.
+func (p *Pty) Read(b []byte) (int, error) {
+    n, err := p.master.Read(b)
+    return n, closedEOF(err)
+}
+
+// Write writes data to be read from the slave by host programs.
+func (p *Pty) Write(b []byte) (int, error) {
+    return p.master.Write(b)
+}
+
+// Close releases the pseudo-terminal.
+func (p *Pty) Close() error {
+    p.slave.Close()
+    return p.master.Close()
+}
+
+// rawMode disables input and output processing, echo and signals on the slave.
+func (p *Pty) rawMode() error {
+    _, err := rawMode(p.slave.Fd())
+    return err
+}
+
+// Terminal is a host terminal device, such as the controlling terminal
+// /dev/tty, in raw mode. The original mode of the terminal is restored when it
+// is closed.
+type Terminal struct {
+    f *os.File
+    saved syscall.Termios
+}
+
+// OpenTerminal opens the terminal device name and puts it into raw mode.
+func OpenTerminal(name string) (*Terminal, error) {
+    f, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+    if err != nil {
+        return nil, err
+    }
+    saved, err := rawMode(f.Fd())
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    return &Terminal{f: f, saved: saved}, nil
+}
+
+// Read reads data typed at the terminal. io.EOF is returned once the terminal
+// is closed.
+func (t *Terminal) Read(b []byte) (int, error) {
+    n, err := t.f.Read(b)
+    return n, closedEOF(err)
+}
+
+// Write writes data to the terminal.
+func (t *Terminal) Write(b []byte) (int, error) {
+    return t.f.Write(b)
+}
+
+// Restore returns the terminal to its original mode.
+func (t *Terminal) Restore() error {
+    return ioctl(t.f.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&t.saved)))
+}
+
+// Close restores the terminal mode and closes the terminal.
+func (t *Terminal) Close() error {
+    err := t.Restore()
+    if cerr := t.f.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+// rawMode disables input and output processing, echo and signals on the
+// terminal fd. The previous mode is returned.
+func rawMode(fd uintptr) (syscall.Termios, error) {
+    var saved syscall.Termios
+    if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&saved))); err != nil {
+        return saved, err
+    }
+    t := saved
+    t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+        syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+    t.Oflag &^= syscall.OPOST
+    t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+    t.Cflag &^= syscall.CSIZE | syscall.PARENB
+    t.Cflag |= syscall.CS8
+    t.Cc[syscall.VMIN] = 1
+    t.Cc[syscall.VTIME] = 0
+    return saved, ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+}
+
+// closedEOF returns io.EOF in place of the errors reported when a terminal is
+// read after it has been closed or hung up.
+func closedEOF(err error) error {
+    if errors.Is(err, os.ErrClosed) || errors.Is(err, syscall.EIO) {
+        return io.EOF
+    }
+    return err
+}
+
+func ioctl(fd, req, arg uintptr) error {
+    _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+    if errno != 0 {
+        return errno
+    }
+    return nil
+}
@@ -0,0 +1,85 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build !linux
+// +build !linux
+
+package nova
+
+import "errors"
+
+// Pty is a host pseudo-terminal. Pseudo-terminals are only supported on Linux.
+type Pty struct{}
+
+// OpenPty returns an error as pseudo-terminals are not supported.
+func OpenPty() (*Pty, error) {
+    return nil, errors.New("pseudo-terminals not supported")
+}
+
+// Name returns the path of the slave device.
+func (p *Pty) Name() string {
+    return ""
+}
+
+// Read implements io.Reader.
+func (p *Pty) Read(b []byte) (int, error) {
+    return 0, errors.New("pseudo-terminals not supported")
+}
+
+// Write implements io.Writer.
+func (p *Pty) Write(b []byte) (int, error) {
+    return 0, errors.New("pseudo-terminals not supported")
+}
+
+// Close implements io.Closer.
+func (p *Pty) Close() error {
+    return nil
+}
+
+// Terminal is a host terminal device in raw mode. Raw mode is only supported
+// on Linux.
+type Terminal struct{}
+
+// OpenTerminal returns an error as raw mode is not supported.
+func OpenTerminal(name string) (*Terminal, error) {
+    return nil, errors.New("raw terminal mode not supported")
+}
+
+// Read implements io.Reader.
+func (t *Terminal) Read(b []byte) (int, error) {
+    return 0, errors.New("raw terminal mode not supported")
+}
+
+// Write implements io.Writer.
+func (t *Terminal) Write(b []byte) (int, error) {
+    return 0, errors.New("raw terminal mode not supported")
+}
+
+// Restore returns the terminal to its original mode.
+func (t *Terminal) Restore() error {
+    return nil
+}
+
+// Close implements io.Closer.
+func (t *Terminal) Close() error {
+    return nil
+}
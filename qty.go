@@ -0,0 +1,159 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "time"
+)
+
+// QTY status bits returned by DIB. The line number is returned in bits 10-15.
+const (
+    qtyRecv = 0100000   // Character received
+    qtyXmit = 0040000   // Transmitter ready
+)
+
+// qty emulates the 4060 asynchronous line multiplexer. Done is set while any
+// line has received a character or has finished transmitting a character. DIB
+// returns the status and number of the next line requiring service, giving
+// priority to received characters. DIA returns the character received on the
+// line last reported by DIB. DOA transmits the character in bits 8-15 on the
+// line specified by bits 2-7. A line that cannot be written is detached unless
+// the error policy is ErrorIgnore.
+type qty struct {
+    controller
+    m *mux
+    line int    // Line last reported by DIB
+}
+
+// newQTY creates a multiplexer with count lines that transmit rate characters
+// per second.
+func newQTY(n *Nova, num, pri uint16, count int, rate float32) *qty {
+    d := &qty{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        m: newMux(count, rate),
+    }
+    go d.device()
+    return d
+}
+
+func (d *qty) device() {
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                d.m.reset()
+            case ioDIA:
+                msg.data = uint16(d.m.receive(d.line))
+            case ioDIB:
+                msg.data = d.status()
+            case ioDOA:
+                line := int(msg.data >> 8)&077
+                if line < len(d.m.lines) {
+                    d.m.transmit(line, byte(msg.data))
+                }
+            case ioNIO, ioDOB, ioDIC, ioDOC:
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            d.update()
+            d.dev <- msg    // Ack
+        case e := <-d.m.events:
+            if d.m.event(e, d.fail) != nil {
+                d.update()
+            }
+        }
+    }
+}
+
+// status returns the status of the next line requiring service. A transmitter
+// ready condition is cleared once reported.
+func (d *qty) status() uint16 {
+    for i := range d.m.lines {
+        if d.m.lines[i].recv {
+            d.line = i
+            return qtyRecv | uint16(i)
+        }
+    }
+    for i := range d.m.lines {
+        l := &d.m.lines[i]
+        if l.xmit {
+            l.xmit = false
+            d.line = i
+            return qtyXmit | uint16(i)
+        }
+    }
+    return 0
+}
+
+// update sets Done if any line requires service.
+func (d *qty) update() {
+    for i := range d.m.lines {
+        l := &d.m.lines[i]
+        if l.recv || l.xmit {
+            if d.state != devDone {
+                d.state = devDone
+                d.n.setInt(d.num)
+            }
+            return
+        }
+    }
+    d.idle()
+}
+
+// attachLine attaches media to a line.
+func (d *qty) attachLine(line int, rw io.ReadWriter) error {
+    if line < 0 || line >= len(d.m.lines) {
+        return fmt.Errorf("%s: no line %d", deviceName(d.num), line)
+    }
+    d.sync(func() {
+        d.m.attach(line, rw)
+    })
+    return nil
+}
+
+// lines returns the number of lines.
+func (d *qty) lines() int {
+    return len(d.m.lines)
+}
+
+// mediaErrors reports that the multiplexer media can fail.
+func (d *qty) mediaErrors() {}
+
+// setRate sets the character transmission time of every line.
+func (d *qty) setRate(period time.Duration) {
+    d.sync(func() {
+        d.m.period = period
+    })
+}
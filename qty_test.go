@@ -0,0 +1,180 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bufio"
+    "errors"
+    "io"
+    "net"
+    "sync/atomic"
+    "time"
+
+    "testing"
+)
+
+func TestQTY(t *testing.T) {
+    n := NewNova()
+    p, err := ListenTelnet("127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+    err = n.AttachLine(DevQTY, 5, p)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    c, err := net.Dial("tcp", p.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer c.Close()
+    c.SetDeadline(time.Now().Add(time.Second))
+    r := bufio.NewReader(c)
+
+    // Server offers ECHO and SGA
+    negotiation := []byte{
+        telIAC, telWILL, telEcho,
+        telIAC, telWILL, telSGA,
+        telIAC, telDO, telSGA,
+    }
+    for i, want := range negotiation {
+        have, err := r.ReadByte()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if have != want {
+            t.Errorf("byte %d: have: %d, want: %d", i, have, want)
+        }
+    }
+
+    // Receive character, with an embedded telnet command
+    c.Write([]byte{telIAC, telDO, telEcho, 'A', '\r', 0})
+    d := n.devices[DevQTY]
+    waitDone(t, d)
+    status := d.read(ioDIB, 0)
+    if status != qtyRecv|5 {
+        t.Errorf("status: have: %06o, want: %06o", status, qtyRecv|5)
+    }
+    char := d.read(ioDIA, 0)
+    if char != 'A' {
+        t.Errorf("char: have: %03o, want: %03o", char, 'A')
+    }
+
+    // Transmit character
+    d.write(ioDOA, 0, 5 << 8 | 'B')
+    have, err := r.ReadByte()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if have != 'B' {
+        t.Errorf("have: %03o, want: %03o", have, 'B')
+    }
+}
+
+func TestQTYReattach(t *testing.T) {
+    n := NewNova()
+    p, err := ListenTelnet("127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+    conn, err := net.Dial("tcp", p.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    waitFor(t, p.Connected)
+
+    d := n.devices[DevQTY]
+    for _, c := range []byte("AB") {
+        // The reader of the previous attachment must not take the character
+        err = n.AttachLine(DevQTY, 5, p)
+        if err != nil {
+            t.Fatal(err)
+        }
+        conn.Write([]byte{c})
+        waitDone(t, d)
+        status := d.read(ioDIB, 0)
+        if status != qtyRecv|5 {
+            t.Errorf("status: have: %06o, want: %06o", status, qtyRecv|5)
+        }
+        char := d.read(ioDIA, 0)
+        if char != uint16(c) {
+            t.Errorf("char: have: %03o, want: %03o", char, c)
+        }
+    }
+}
+
+// brokenLine is a line whose writes fail.
+type brokenLine struct {
+    writes atomic.Int32
+}
+
+func (l *brokenLine) Read(b []byte) (int, error) {
+    return 0, io.EOF
+}
+
+func (l *brokenLine) Write(b []byte) (int, error) {
+    l.writes.Add(1)
+    return 0, errBrokenPipe
+}
+
+func TestQTYWriteError(t *testing.T) {
+    n := NewNova()
+    l := &brokenLine{}
+    err := n.AttachLine(DevQTY, 5, l)
+    if err != nil {
+        t.Fatal(err)
+    }
+    d := n.devices[DevQTY]
+
+    // The line is detached after the failed write
+    for i := 0; i < 2; i++ {
+        d.write(ioDOA, 0, 5 << 8 | 'B')
+        waitDone(t, d)
+        status := d.read(ioDIB, 0)
+        if status != qtyXmit|5 {
+            t.Errorf("status: have: %06o, want: %06o", status, qtyXmit|5)
+        }
+    }
+    if writes := l.writes.Load(); writes != 1 {
+        t.Errorf("writes: have: %d, want: 1", writes)
+    }
+    if e := <-n.DeviceErrors(); e.Code != DevQTY || !errors.Is(e, errBrokenPipe) {
+        t.Errorf("have: %v, want: QTY broken pipe", e)
+    }
+
+    // An ignored error leaves the line attached
+    n.SetErrorPolicy(DevQTY, ErrorIgnore)
+    n.AttachLine(DevQTY, 5, l)
+    for i := 0; i < 2; i++ {
+        d.write(ioDOA, 0, 5 << 8 | 'B')
+        waitDone(t, d)
+        d.read(ioDIB, 0)
+    }
+    if writes := l.writes.Load(); writes != 3 {
+        t.Errorf("writes: have: %d, want: 3", writes)
+    }
+}
@@ -22,19 +22,21 @@
 
 package nova
 
-import "time"
+import (
+    "time"
+)
 
 type rtc struct {
     controller
 }
 
-func newrtc(n *Nova, lineFreq int) *rtc {
-    if lineFreq != 50 || lineFreq !=  60 {
+func newrtc(n *Nova, num uint16, lineFreq int) *rtc {
+    if lineFreq != 50 && lineFreq != 60 {
         lineFreq = 60
     }
     d := &rtc{
         controller: controller{
-            num: devRTC,
+            num: num,
             pri: priRTC,
             dev: make(chan devmsg),
             n: n,
@@ -68,8 +70,10 @@ func (d *rtc) device(lineFreq int) {
                 d.flags(msg)
             case ioSKP:
                 msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
             default:
-                panic("RTC: invalid message type")
+                d.invalid(msg)
             }
             d.dev <- msg    // Ack
         case <-ticker.C:
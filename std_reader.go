@@ -25,12 +25,116 @@ package nova
 import (
     "time"
     "io"
+    "io/ioutil"
+    "bytes"
     "fmt"
 )
 
+// inputChar is a character read by an input pump. A non-nil err reports a
+// failed read.
+type inputChar struct {
+    c byte
+    err error
+}
+
+// pump reads a character from r into in each time one is requested on want,
+// until the read fails or stop is closed. Read errors other than io.EOF are
+// passed on before in is closed.
+func pump(r io.Reader, want <-chan struct{}, in chan<- inputChar, stop <-chan struct{}) {
+    defer close(in)
+    b := make([]byte, 1)
+    for {
+        select {
+        case <-want:
+        case <-stop:
+            return
+        }
+        n, err := r.Read(b)
+        for n == 0 && err == nil {
+            n, err = r.Read(b)
+        }
+        if n > 0 {
+            select {
+            case in <- inputChar{c: b[0]}:
+            case <-stop:
+                return
+            }
+            continue
+        }
+        if err != io.EOF {
+            select {
+            case in <- inputChar{err: err}:
+            case <-stop:
+            }
+        }
+        return
+    }
+}
+
+// reel is media loaded into a reader. The frames of seekable media are held in
+// tape.
+type reel struct {
+    r io.Reader
+    tape []byte
+}
+
+// TapePosition describes the position of the tape in a paper tape reader.
+// Length, Leader and Trailer are only fully known for seekable media, such as
+// files; for other media Length is -1 and Trailer is false.
+type TapePosition struct {
+    Frame int       // Frames read by the program
+    Length int      // Frames on the tape
+    Leader bool     // Only leader has been read
+    Trailer bool    // All data has been read; only trailer remains
+    End bool        // Reader has run off the end of the tape
+}
+
+// tapeOp is a console request to position the tape at frame, or only to
+// report the position if frame is negative.
+type tapeOp struct {
+    frame int
+    pos chan TapePosition
+}
+
+// stdReader emulates a character input device. Attached media is read
+// asynchronously, a character at a time as the program asks for one, so that
+// a blocking reader cannot stall the device. Setting Busy reads the next
+// character; Done is set once the frame time has elapsed and a character is
+// available. Reading past the end of the media leaves the device Busy until
+// new media is attached or the tape is repositioned. If no media is attached,
+// Done is set at once.
+//
+// Seekable media is read into memory when attached, so that the tape can be
+// rewound or repositioned and the leader and trailer detected.
+//
+// If reading the media fails, the device stays Busy while the processor halts.
+// If the device error policy is ErrorStatus, Done is set with the error bit set
+// in the status returned by DIB; Start and Clear clear the status. If it is
+// ErrorIgnore, the read completes with a NUL character, or a keyboard carries
+// on reading the media for the next key. Otherwise the media is not read after
+// a failure.
+//
+// A keyboard reader is an unsolicited input device. Done is set, and an
+// interrupt requested, whenever a character is struck, whether or not Busy is
+// set. Characters struck while Done is set are not read until Done is
+// cleared, and characters are accepted no faster than the frame
+// rate. No characters arrive after the end of the media.
 type stdReader struct {
     controller
-    r io.Reader
+    keyboard bool
+    media io.Reader         // Media being read
+    in chan inputChar       // Characters read; nil if no media
+    want chan struct{}      // Requests a character from the pump
+    pending bool            // Character requested but not yet received
+    stop chan struct{}      // Closed to stop the pump
+    readers chan reel       // Media to attach
+    tapeops chan tapeOp
+    tape []byte             // Seekable media
+    pos int                 // Frames read by the program
+    punched bool            // Frame other than NUL has been read
+    end bool                // Read past end of media
+    period time.Duration    // Frame time
+    status uint16
 }
 
 func newStdReader(n *Nova, num, pri uint16, rate float32) *stdReader {
@@ -41,58 +145,301 @@ func newStdReader(n *Nova, num, pri uint16, rate float32) *stdReader {
             dev: make(chan devmsg),
             n: n,
         },
+        readers: make(chan reel),
+        tapeops: make(chan tapeOp),
+        period: framePeriod(rate),
     }
-    go d.device(rate)
+    go d.device()
     return d
 }
 
-func (d *stdReader) device(rate float32) {
-    period := time.Duration(float32(time.Second)/rate)
+// newKeyboard creates a keyboard reader.
+func newKeyboard(n *Nova, num, pri uint16, rate float32) *stdReader {
+    d := &stdReader{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        keyboard: true,
+        readers: make(chan reel),
+        tapeops: make(chan tapeOp),
+        period: framePeriod(rate),
+    }
+    go d.device()
+    return d
+}
+
+func (d *stdReader) device() {
     t := time.NewTimer(time.Second * 1)
     t.Stop()
     expired := true
+    starved := false    // Frame time elapsed without a character
     for {
+        var waiting <-chan inputChar    // Input while waiting for a character
+        if d.keyboard {
+            // Accept a character once Done is clear and the previous frame
+            // has passed
+            if d.state != devDone && expired {
+                waiting = d.in
+            }
+        } else if starved {
+            waiting = d.in
+        }
+        if waiting != nil {
+            d.request()
+        }
         select {
         case msg := <-d.dev:
             switch msg.typ {
             case ioRST:
+                if !t.Stop() && !expired {
+                    <-t.C
+                }
+                expired = true
+                starved = false
+                d.status = 0
                 d.idle()
             case ioDIA:
                 msg.data = d.data
                 fallthrough
             case ioNIO, ioDOA, ioDIB, ioDOB, ioDIC, ioDOC:
-                if msg.flags == ioS {
+                if msg.typ == ioDIB {
+                    msg.data = d.status
+                }
+                if msg.flags == ioS || msg.flags == ioC {
+                    d.status = 0
+                }
+                if !d.keyboard && msg.flags == ioS {
                     // Start device; delay until end of frame before read
                     if !t.Stop() && !expired {
                         <-t.C
                     }
-                    t.Reset(period)
+                    t.Reset(d.period)
                     expired = false
+                    starved = false
+                    d.request()
+                } else if !d.keyboard && msg.flags == ioC {
+                    starved = false
                 }
                 d.flags(msg)
             case ioSKP:
                 msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
             default:
-                panic(fmt.Sprintf("%s: invalid message type", deviceName(d.num)))
+                d.invalid(msg)
             }
             d.dev <- msg    // Ack
         case <-t.C:
             // Read from device
             expired = true
-            if d.r != nil {
-                b := make([]byte, 1)
-                if _, err := d.r.Read(b); err != nil {
-                    if err != io.EOF {
-                        panic(fmt.Sprintf("%s: %v", deviceName(d.num), err))
-                    }
+            if d.keyboard {
+                break
+            }
+            if d.in == nil && !d.end {
+                d.complete()
+                break
+            }
+            select {
+            case ch, ok := <-d.in:
+                d.pending = false
+                starved = !d.receive(ch, ok)
+            default:
+                // Wait for input
+                starved = true
+            }
+        case ch, ok := <-waiting:
+            d.pending = false
+            if d.keyboard {
+                if !ok {
+                    // No more keys
+                    d.in = nil
+                    break
                 }
-                d.data = uint16(b[0])
+                d.strike(ch)
+                t.Reset(d.period)
+                expired = false
+                break
+            }
+            starved = !d.receive(ch, ok)
+        case r := <-d.readers:
+            d.tape = r.tape
+            d.pos = 0
+            d.punched = false
+            d.end = false
+            d.load(r.r)
+        case op := <-d.tapeops:
+            if op.frame >= 0 && d.tape != nil {
+                d.position(op.frame)
             }
-            d.complete()
+            op.pos <- d.position(-1)
         }
     }
 }
 
-func (d *stdReader) attach(r io.Reader) {
-    d.r = r
+// load starts a pump for r, discarding any character requested from the
+// previous media. If r is nil, the previous media is unloaded.
+func (d *stdReader) load(r io.Reader) {
+    if d.stop != nil {
+        close(d.stop)
+        d.stop = nil
+    }
+    d.pending = false
+    d.media = r
+    if r == nil {
+        d.in = nil
+        return
+    }
+    d.stop = make(chan struct{})
+    d.want = make(chan struct{}, 1)
+    d.in = make(chan inputChar, 1)
+    go pump(r, d.want, d.in, d.stop)
+}
+
+// request asks the pump for the next character, unless one has already been
+// requested.
+func (d *stdReader) request() {
+    if d.in == nil || d.pending {
+        return
+    }
+    d.want <- struct{}{}
+    d.pending = true
+}
+
+// receive completes a read with the character ch and reports whether the read
+// completed. If ok is false, the reader has run off the end of the media and
+// the read does not complete.
+func (d *stdReader) receive(ch inputChar, ok bool) bool {
+    if !ok {
+        d.in = nil
+        d.end = true
+        return false
+    }
+    if ch.err != nil {
+        return d.readError(ch.err)
+    }
+    d.pos++
+    if ch.c != 0 {
+        d.punched = true
+    }
+    d.data = uint16(ch.c)
+    d.complete()
+    return true
+}
+
+// position moves the tape held in memory to frame and returns the tape
+// position. If frame is negative, the tape is not moved.
+func (d *stdReader) position(frame int) TapePosition {
+    if frame >= 0 {
+        if frame > len(d.tape) {
+            frame = len(d.tape)
+        }
+        d.pos = frame
+        d.end = false
+        d.punched = false
+        for _, c := range d.tape[:frame] {
+            if c != 0 {
+                d.punched = true
+                break
+            }
+        }
+        d.load(bytes.NewReader(d.tape[frame:]))
+    }
+
+    p := TapePosition{
+        Frame: d.pos,
+        Length: -1,
+        Leader: !d.punched,
+        End: d.end,
+    }
+    if d.tape != nil {
+        // Trailer starts after the last frame other than NUL
+        last := len(d.tape)
+        for last > 0 && d.tape[last - 1] == 0 {
+            last--
+        }
+        p.Length = len(d.tape)
+        p.Trailer = d.punched && d.pos >= last
+    }
+    return p
+}
+
+// readError reports a failure to read the media and returns whether the read
+// completed.
+func (d *stdReader) readError(err error) bool {
+    switch d.fail(err) {
+    case ErrorStatus:
+        d.status |= stdError
+    case ErrorIgnore:
+        if d.keyboard {
+            // Wait for the next key
+            d.load(d.media)
+            return false
+        }
+    default:
+        return false
+    }
+    d.data = 0
+    d.state = devDone
+    d.n.setInt(d.num)
+    return true
+}
+
+// strike sets Done with the character ch struck at the keyboard.
+func (d *stdReader) strike(ch inputChar) {
+    if ch.err != nil {
+        d.readError(ch.err)
+        return
+    }
+    d.data = uint16(ch.c)
+    d.state = devDone
+    d.n.setInt(d.num)
+}
+
+// attach replaces the media. A character requested from the previous media is
+// discarded. Seekable media is read into memory, unless the reader is a
+// keyboard. A nil reader unloads the media; a read in progress waits for new
+// media.
+func (d *stdReader) attach(r io.Reader) error {
+    if seekable(r) && !d.keyboard {
+        tape, err := ioutil.ReadAll(r)
+        if err != nil {
+            return fmt.Errorf("%s: %v", deviceName(d.num), err)
+        }
+        d.readers <- reel{bytes.NewReader(tape), tape}
+        return nil
+    }
+    d.readers <- reel{r: r}
+    return nil
+}
+
+// seekable reports whether r can be repositioned. Files such as os.Stdin may
+// be pipes or terminals, which cannot.
+func seekable(r io.Reader) bool {
+    s, ok := r.(io.Seeker)
+    if !ok {
+        return false
+    }
+    _, err := s.Seek(0, io.SeekCurrent)
+    return err == nil
+}
+
+// mediaErrors reports that the reader media can fail.
+func (d *stdReader) mediaErrors() {}
+
+// setRate sets the frame time.
+func (d *stdReader) setRate(period time.Duration) {
+    d.sync(func() {
+        d.period = period
+    })
+}
+
+// tapeOp positions the tape at frame, if frame is not negative, and returns
+// the tape position.
+func (d *stdReader) tapeOp(frame int) TapePosition {
+    op := tapeOp{frame, make(chan TapePosition)}
+    d.tapeops <- op
+    return <-op.pos
 }
@@ -0,0 +1,262 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "os"
+    "testing"
+    "testing/iotest"
+)
+
+func TestStdReaderPump(t *testing.T) {
+    n := NewNova()
+    r, w := io.Pipe()
+    defer w.Close()
+    err := n.Attach(DevPTR, r)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // A blocked reader does not stall the device
+    d := n.devices[DevPTR]
+    d.write(ioNIO, ioS, 0)
+    if !d.test(ioBN) || d.test(ioDN) {
+        t.Error("have: not busy or done, want: busy")
+    }
+
+    // Characters are read in order
+    go w.Write([]byte("ab"))
+    for _, want := range []uint16{'a', 'b'} {
+        waitDone(t, d)
+        if c := d.read(ioDIA, ioS); c != want {
+            t.Errorf("have: %03o, want: %03o", c, want)
+        }
+    }
+
+    // Reset while waiting
+    d.write(ioRST, 0, 0)
+    if d.test(ioBN) || d.test(ioDN) {
+        t.Error("have: busy or done, want: idle")
+    }
+}
+
+func TestKeyboard(t *testing.T) {
+    n := NewNova()
+    r, w := io.Pipe()
+    defer w.Close()
+    err := n.Attach(DevTTI, r)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Done is set without starting the device
+    d := n.devices[DevTTI].(*stdReader)
+    reading := func() (pending, end bool) {
+        d.sync(func() {
+            pending, end = d.pending || len(d.in) > 0, d.in == nil
+        })
+        return pending, end
+    }
+    go func() {
+        w.Write([]byte("xy"))
+        w.Close()
+    }()
+    for _, want := range []uint16{'x', 'y'} {
+        waitDone(t, d)
+        if d.test(ioBN) {
+            t.Error("busy: have: true, want: false")
+        }
+
+        // Next character is not read until Done is cleared
+        if pending, _ := reading(); pending {
+            t.Error("pending: have: true, want: false")
+        }
+        if c := d.read(ioDIA, ioC); c != want {
+            t.Errorf("have: %03o, want: %03o", c, want)
+        }
+    }
+
+    // No Done at the end of the media
+    waitFor(t, func() bool {
+        _, end := reading()
+        return end
+    })
+    if d.test(ioDN) {
+        t.Error("done: have: true, want: false")
+    }
+}
+
+func TestPaperTapeReader(t *testing.T) {
+    n := NewNova()
+    err := n.Attach(DevPTR, bytes.NewReader([]byte{0, 0, 'A', 'B', 0}))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    d := n.devices[DevPTR]
+    read := func() uint16 {
+        d.write(ioNIO, ioS, 0)
+        waitDone(t, d)
+        return d.read(ioDIA, 0)
+    }
+    check := func(want TapePosition) {
+        t.Helper()
+        pos, err := n.ReaderPosition(DevPTR)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if pos != want {
+            t.Errorf("have: %+v, want: %+v", pos, want)
+        }
+    }
+
+    read()
+    check(TapePosition{Frame: 1, Length: 5, Leader: true})
+    read()
+    if c := read(); c != 'A' {
+        t.Errorf("have: %03o, want: %03o", c, 'A')
+    }
+    check(TapePosition{Frame: 3, Length: 5})
+    read()
+    check(TapePosition{Frame: 4, Length: 5, Trailer: true})
+    read()
+
+    // Reading past the end leaves the reader busy
+    d.write(ioNIO, ioS, 0)
+    waitFor(t, func() bool {
+        pos, _ := n.ReaderPosition(DevPTR)
+        return pos.End
+    })
+    if !d.test(ioBN) || d.test(ioDN) {
+        t.Error("have: not busy or done, want: busy")
+    }
+    check(TapePosition{Frame: 5, Length: 5, Trailer: true, End: true})
+
+    // Repositioning resumes the read
+    err = n.PositionReader(DevPTR, 3)
+    if err != nil {
+        t.Fatal(err)
+    }
+    waitDone(t, d)
+    if c := d.read(ioDIA, 0); c != 'B' {
+        t.Errorf("have: %03o, want: %03o", c, 'B')
+    }
+
+    err = n.RewindReader(DevPTR)
+    if err != nil {
+        t.Fatal(err)
+    }
+    check(TapePosition{Frame: 0, Length: 5, Leader: true})
+
+    // Unseekable tapes cannot be positioned
+    r, w := io.Pipe()
+    defer w.Close()
+    n.Attach(DevPTR, r)
+    if err := n.RewindReader(DevPTR); err == nil {
+        t.Error("have: nil, want: error")
+    }
+    check(TapePosition{Length: -1, Leader: true})
+}
+
+func TestStdReaderError(t *testing.T) {
+    n := NewNova()
+    n.SetErrorPolicy(DevPTR, ErrorIgnore)
+    err := n.Attach(DevPTR, iotest.ErrReader(errors.New("read error")))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The read completes with a NUL character
+    d := n.devices[DevPTR]
+    d.write(ioNIO, ioS, 0)
+    waitDone(t, d)
+    if c := d.read(ioDIA, 0); c != 0 {
+        t.Errorf("data: have: %03o, want: 0", c)
+    }
+    if s := d.read(ioDIB, 0); s != 0 {
+        t.Errorf("status: have: %06o, want: 0", s)
+    }
+}
+
+func TestStdReaderPipe(t *testing.T) {
+    n := NewNova()
+    r, w, err := os.Pipe()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer r.Close()
+    defer w.Close()
+
+    // Files that cannot seek are read as they arrive, not when attached
+    err = n.Attach(DevPTR, r)
+    if err != nil {
+        t.Fatal(err)
+    }
+    pos, err := n.ReaderPosition(DevPTR)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if pos.Length != -1 {
+        t.Errorf("length: have: %d, want: -1", pos.Length)
+    }
+    d := n.devices[DevPTR]
+    w.Write([]byte("A"))
+    d.write(ioNIO, ioS, 0)
+    waitDone(t, d)
+    if c := d.read(ioDIA, 0); c != 'A' {
+        t.Errorf("have: %03o, want: %03o", c, 'A')
+    }
+}
+
+// flakyReader fails its first read and then reads from r.
+type flakyReader struct {
+    r io.Reader
+    failed bool
+}
+
+func (f *flakyReader) Read(b []byte) (int, error) {
+    if !f.failed {
+        f.failed = true
+        return 0, errors.New("read error")
+    }
+    return f.r.Read(b)
+}
+
+func TestKeyboardError(t *testing.T) {
+    n := NewNova()
+    n.SetErrorPolicy(DevTTI, ErrorIgnore)
+    err := n.Attach(DevTTI, &flakyReader{r: bytes.NewReader([]byte("A"))})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The keyboard carries on reading after the error
+    d := n.devices[DevTTI]
+    waitDone(t, d)
+    if c := d.read(ioDIA, ioC); c != 'A' {
+        t.Errorf("have: %03o, want: %03o", c, 'A')
+    }
+}
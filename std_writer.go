@@ -25,12 +25,20 @@ package nova
 import (
     "time"
     "io"
-    "fmt"
 )
 
+// Character device status bits returned by DIB.
+const stdError = 0100000    // Character could not be transferred
+
+// stdWriter emulates a character output device. DOA loads the character and
+// setting Busy writes it to the attached media. Done is set once the frame
+// time has elapsed. DIB returns the device status, which is cleared by Start
+// and Clear.
 type stdWriter struct {
     controller
     w io.Writer
+    period time.Duration    // Frame time
+    status uint16
 }
 
 func newStdWriter(n *Nova, num, pri uint16, rate float32) *stdWriter {
@@ -41,13 +49,13 @@ func newStdWriter(n *Nova, num, pri uint16, rate float32) *stdWriter {
             dev: make(chan devmsg),
             n: n,
         },
+        period: framePeriod(rate),
     }
-    go d.device(rate)
+    go d.device()
     return d
 }
 
-func (d *stdWriter) device(rate float32) {
-    period := time.Duration(float32(time.Second)/rate)
+func (d *stdWriter) device() {
     t := time.NewTimer(time.Second * 1)
     t.Stop()
     expired := true
@@ -56,25 +64,34 @@ func (d *stdWriter) device(rate float32) {
         case msg := <-d.dev:
             switch msg.typ {
             case ioRST:
+                d.status = 0
                 d.idle()
             case ioDOA:
                 // Load output register
                 d.data = msg.data
                 fallthrough
             case ioNIO, ioDIA, ioDIB, ioDOB, ioDIC, ioDOC:
+                if msg.typ == ioDIB {
+                    msg.data = d.status
+                }
+                if msg.flags == ioS || msg.flags == ioC {
+                    d.status = 0
+                }
                 if msg.flags == ioS {
                     // Start device; delay until end of frame before write
                     if !t.Stop() && !expired {
                         <-t.C
                     }
-                    t.Reset(period)
+                    t.Reset(d.period)
                     expired = false
                 }
                 d.flags(msg)
             case ioSKP:
                 msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
             default:
-                panic(fmt.Sprintf("%s: invalid message type", deviceName(d.num)))
+                d.invalid(msg)
             }
             d.dev <- msg    // Ack
         case <-t.C:
@@ -82,8 +99,8 @@ func (d *stdWriter) device(rate float32) {
             expired = true
             if d.w != nil {
                 b := []byte{byte(d.data)}
-                if _, err := d.w.Write(b); err != nil {
-                    panic(fmt.Sprintf("%s: %v", deviceName(d.num), err))
+                if _, err := d.w.Write(b); err != nil && d.fail(err) == ErrorStatus {
+                    d.status |= stdError
                 }
             }
             d.complete()
@@ -91,6 +108,23 @@ func (d *stdWriter) device(rate float32) {
     }
 }
 
+// mediaErrors reports that the writer media can fail.
+func (d *stdWriter) mediaErrors() {}
+
+// setRate sets the frame time.
+func (d *stdWriter) setRate(period time.Duration) {
+    d.sync(func() {
+        d.period = period
+    })
+}
+
+// attach replaces the media. A nil writer detaches the media. The previous
+// media is flushed if it buffers its output.
 func (d *stdWriter) attach(w io.Writer) {
-    d.w = w
+    d.sync(func() {
+        if err := flush(d.w); err != nil && d.fail(err) == ErrorStatus {
+            d.status |= stdError
+        }
+        d.w = w
+    })
 }
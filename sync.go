@@ -0,0 +1,334 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "fmt"
+    "net"
+    "time"
+)
+
+// Default synchronous idle character (EBCDIC SYN).
+const kSyncChar = 0x32
+
+// Synchronous adapter status bits returned by DIA in bits 0-7. The received
+// character is returned in bits 8-15.
+const (
+    scaRecv    = 0100000    // Character received
+    scaXmit    = 0040000    // Transmitter ready
+    scaSync    = 0020000    // Receiver in character synchronization
+    scaOverrun = 0010000    // Received character lost
+    scaLine    = 0004000    // Line connected
+)
+
+// Synchronous adapter control bits loaded by DOC.
+const (
+    scaResetRecvCRC = 0000001   // Clear receive CRC
+    scaResetXmitCRC = 0000002   // Clear transmit CRC
+    scaHunt         = 0000004   // Search for synchronization
+)
+
+// sca emulates a synchronous communications adapter for binary synchronous
+// (BSC) lines. After a reset the receiver hunts for two consecutive sync
+// characters; every character received after synchronization is available
+// to DIA and added to the receive CRC. DOA transmits the character in bits
+// 8-15 and adds it to the transmit CRC; Busy is set until the character has
+// been sent. Done is set, and an interrupt requested, when a character has
+// been received or the transmitter becomes ready. DIA returns the status and
+// received character, clearing the receive and transmitter ready conditions.
+// DIB returns the receive CRC and DIC the transmit CRC, both CRC-16 computed
+// least significant bit first. DOB loads the sync character and DOC controls
+// the CRCs and synchronization.
+type sca struct {
+    controller
+    line io.ReadWriter
+    gen int                 // Line generation, incremented on each attach
+    lines chan io.ReadWriter
+    in chan scaChar         // Characters received
+    out chan byte           // Characters to transmit
+    syncChar byte
+    syncs int               // Consecutive sync characters while hunting
+    status uint16
+    char byte               // Received character
+    rcrc uint16             // Receive CRC
+    xcrc uint16             // Transmit CRC
+}
+
+// scaChar is a character received from the line with generation gen. A
+// negative c indicates that the line has disconnected.
+type scaChar struct {
+    c int
+    gen int
+}
+
+// newSCA creates a synchronous adapter for a line running at rate characters
+// per second.
+func newSCA(n *Nova, num, pri uint16, rate float32) *sca {
+    d := &sca{
+        controller: controller{
+            num: num,
+            pri: pri,
+            dev: make(chan devmsg),
+            n: n,
+        },
+        lines: make(chan io.ReadWriter),
+        in: make(chan scaChar),
+        syncChar: kSyncChar,
+    }
+    go d.device(rate)
+    return d
+}
+
+func (d *sca) device(rate float32) {
+    period := time.Duration(float32(time.Second)/rate)
+    t := time.NewTimer(time.Second)
+    t.Stop()
+    sending := false
+    for {
+        select {
+        case msg := <-d.dev:
+            switch msg.typ {
+            case ioRST:
+                if !t.Stop() && sending {
+                    <-t.C
+                }
+                sending = false
+                d.syncChar = kSyncChar
+                d.hunt()
+                d.status &= scaLine
+                d.rcrc, d.xcrc = 0, 0
+                d.idle()
+            case ioDIA:
+                msg.data = d.status | uint16(d.char)
+                d.status &^= scaRecv | scaXmit | scaOverrun
+                d.update()
+            case ioDIB:
+                msg.data = d.rcrc
+            case ioDIC:
+                msg.data = d.xcrc
+            case ioDOA:
+                c := byte(msg.data)
+                d.xcrc = crcUpdate(d.xcrc, c, CRC16, true)
+                d.send(c)
+                d.status &^= scaXmit
+                if !t.Stop() && sending {
+                    <-t.C
+                }
+                t.Reset(period)
+                sending = true
+                d.state = devBusy
+                d.update()
+            case ioDOB:
+                d.syncChar = byte(msg.data)
+            case ioDOC:
+                if msg.data&scaResetRecvCRC != 0 {
+                    d.rcrc = 0
+                }
+                if msg.data&scaResetXmitCRC != 0 {
+                    d.xcrc = 0
+                }
+                if msg.data&scaHunt != 0 {
+                    d.hunt()
+                }
+            case ioNIO:
+            case ioSKP:
+                msg.data = d.skip(msg)
+            case ioSYNC:
+                msg.fn()
+            default:
+                d.invalid(msg)
+            }
+            if msg.typ != ioSKP && msg.flags == ioC {
+                d.status &^= scaRecv | scaXmit
+                d.update()
+            }
+            d.dev <- msg    // Ack
+        case ch := <-d.in:
+            if ch.gen != d.gen || d.line == nil {
+                // Character from replaced line
+                break
+            }
+            if ch.c < 0 {
+                d.line = nil
+                d.status &^= scaLine
+                d.hunt()
+            } else {
+                d.receive(byte(ch.c))
+            }
+            d.update()
+        case <-t.C:
+            sending = false
+            d.status |= scaXmit
+            if d.state == devBusy {
+                d.state = devIdle
+            }
+            d.update()
+        case line := <-d.lines:
+            d.attachLine(line, period)
+            d.update()
+        }
+    }
+}
+
+// hunt puts the receiver into hunt mode.
+func (d *sca) hunt() {
+    d.status &^= scaSync
+    d.syncs = 0
+}
+
+// receive processes a character received from the line.
+func (d *sca) receive(c byte) {
+    if d.status&scaSync == 0 {
+        if c != d.syncChar {
+            d.syncs = 0
+            return
+        }
+        d.syncs++
+        if d.syncs == 2 {
+            d.status |= scaSync
+        }
+        return
+    }
+    if d.status&scaRecv != 0 {
+        d.status |= scaOverrun
+    }
+    d.char = c
+    d.status |= scaRecv
+    d.rcrc = crcUpdate(d.rcrc, c, CRC16, true)
+}
+
+// update sets Done while a character has been received or the transmitter is
+// ready.
+func (d *sca) update() {
+    if d.status&(scaRecv|scaXmit) != 0 {
+        if d.state != devDone {
+            d.state = devDone
+            d.n.setInt(d.num)
+        }
+    } else if d.state == devDone {
+        d.idle()
+    }
+}
+
+// send queues c for transmission. The character is lost if the line is not
+// connected.
+func (d *sca) send(c byte) {
+    if d.line == nil {
+        return
+    }
+    select {
+    case d.out <- c:
+    default:
+    }
+}
+
+// attachLine replaces the line. A nil line detaches the line.
+func (d *sca) attachLine(line io.ReadWriter, period time.Duration) {
+    if d.out != nil {
+        close(d.out)
+        d.out = nil
+    }
+    d.line = line
+    d.gen++
+    d.hunt()
+    if line == nil {
+        d.status &^= scaLine
+        return
+    }
+    d.status |= scaLine
+    d.out = make(chan byte, 16)
+    go d.writer(line, d.out)
+    go d.reader(line, d.gen, period)
+}
+
+// reader receives characters from the line with generation gen at the line
+// rate.
+func (d *sca) reader(line io.Reader, gen int, period time.Duration) {
+    b := make([]byte, 1)
+    for {
+        if _, err := line.Read(b); err != nil {
+            d.in <- scaChar{-1, gen}
+            return
+        }
+        d.in <- scaChar{int(b[0]), gen}
+        time.Sleep(period)
+    }
+}
+
+// writer transmits queued characters on the line.
+func (d *sca) writer(line io.Writer, out chan byte) {
+    for c := range out {
+        if _, err := line.Write([]byte{c}); err != nil {
+            // Reader reports the disconnection
+            for range out {
+            }
+            return
+        }
+    }
+}
+
+// attachMedia attaches the line. A nil media detaches the line; the caller is
+// responsible for closing the old line.
+func (d *sca) attachMedia(media interface{}) error {
+    line, ok := media.(io.ReadWriter)
+    if !ok && media != nil {
+        return fmt.Errorf("%s: need io.ReadWriter media", deviceName(d.num))
+    }
+    d.lines <- line
+    return nil
+}
+
+// ListenSyncLine listens on the TCP network address and attaches each
+// connection in turn as the line of the synchronous adapter with device code
+// code. A new connection replaces the line and the previous connection is
+// closed. The processor may be running when a connection is made. The
+// listener is returned so that it can be closed by the caller.
+func (n *Nova) ListenSyncLine(code int, address string) (net.Listener, error) {
+    num := uint16(code)&077
+    if _, ok := n.devices[num].(*sca); !ok {
+        return nil, fmt.Errorf("%s: not synchronous adapter", deviceName(num))
+    }
+    l, err := net.Listen("tcp", address)
+    if err != nil {
+        return nil, err
+    }
+    go func() {
+        var prev net.Conn
+        for {
+            c, err := l.Accept()
+            if err != nil {
+                return
+            }
+            if err := n.Attach(code, c); err != nil {
+                c.Close()
+                continue
+            }
+            if prev != nil {
+                prev.Close()
+            }
+            prev = c
+        }
+    }()
+    return l, nil
+}
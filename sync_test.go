@@ -0,0 +1,113 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "net"
+    "time"
+
+    "testing"
+)
+
+func TestSCA(t *testing.T) {
+    n := NewNova()
+    d := n.devices[DevIBM1]
+    host, line := net.Pipe()
+    defer host.Close()
+    if err := d.(mediaDriver).attachMedia(line); err != nil {
+        t.Fatal(err)
+    }
+
+    // Receive a block after synchronization; the CRC includes the check
+    // characters and so must be zero
+    text := []byte{0x40, 'A', 'B', 0x03}
+    var crc uint16
+    for _, c := range text {
+        crc = crcUpdate(crc, c, CRC16, true)
+    }
+    block := append([]byte{0x00, kSyncChar, kSyncChar}, text...)
+    block = append(block, byte(crc), byte(crc >> 8))
+    go host.Write(block)
+    for i, want := range block[3:] {
+        waitDone(t, d)
+        status := d.read(ioDIA, 0)
+        if status&scaSync == 0 || status&scaRecv == 0 {
+            t.Errorf("status %d: have: %06o, want: sync and receive", i, status)
+        }
+        if c := byte(status); c != want {
+            t.Errorf("char %d: have: %03o, want: %03o", i, c, want)
+        }
+    }
+    if crc := d.read(ioDIB, 0); crc != 0 {
+        t.Errorf("receive CRC: have: %06o, want: 0", crc)
+    }
+
+    // Transmit a character
+    d.write(ioDOC, 0, scaResetXmitCRC)
+    d.write(ioDOA, ioS, 'Z')
+    if !d.test(ioBN) {
+        t.Error("busy: have: false, want: true")
+    }
+    b := make([]byte, 1)
+    if _, err := host.Read(b); err != nil || b[0] != 'Z' {
+        t.Errorf("transmit: have: %q %v, want: 'Z'", b, err)
+    }
+    waitDone(t, d)
+    if status := d.read(ioDIA, 0); status&scaXmit == 0 {
+        t.Errorf("status: have: %06o, want: transmitter ready", status)
+    }
+    if crc := d.read(ioDIC, 0); crc != crcUpdate(0, 'Z', CRC16, true) {
+        t.Errorf("transmit CRC: have: %06o", crc)
+    }
+}
+
+func TestListenSyncLine(t *testing.T) {
+    n := NewNova()
+    l, err := n.ListenSyncLine(DevIBM1, "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer l.Close()
+
+    // A new connection replaces the line and closes the previous connection
+    c1, err := net.Dial("tcp", l.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer c1.Close()
+    d := n.devices[DevIBM1]
+    waitFor(t, func() bool { return d.read(ioDIA, 0)&scaLine != 0 })
+    c2, err := net.Dial("tcp", l.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer c2.Close()
+    c1.SetReadDeadline(time.Now().Add(time.Second))
+    if _, err := c1.Read(make([]byte, 1)); err != io.EOF {
+        t.Errorf("have: %v, want: %v", err, io.EOF)
+    }
+    if list := n.Attachments(); len(list) != 1 || list[0].Code != DevIBM1 {
+        t.Errorf("have: %v, want: IBM1", list)
+    }
+}
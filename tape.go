@@ -0,0 +1,245 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "errors"
+    "encoding/binary"
+)
+
+// Tape images use the SIMH tape format. Each block is recorded as a 32-bit
+// little endian byte count, the data padded to an even length, and the byte
+// count again. A file mark is recorded as a zero byte count and the end of
+// medium as a byte count of all ones. Words are recorded high byte first.
+const (
+    tapeMark = 0x00000000
+    tapeEOM  = 0xffffffff
+)
+
+// ErrFileMark is returned when a file mark is read from a tape.
+var ErrFileMark = errors.New("file mark")
+
+// ErrWriteLocked is returned when writing to a tape that cannot be written.
+var ErrWriteLocked = errors.New("write locked")
+
+// TapeRecord is a block or file mark recorded on a tape.
+type TapeRecord struct {
+    Mark bool
+    Words []uint16
+}
+
+// ReadTapeImage returns the records of the tape image read from r.
+func ReadTapeImage(r io.Reader) ([]TapeRecord, error) {
+    var records []TapeRecord
+    for {
+        words, err := readTapeRecord(r)
+        switch err {
+        case nil:
+            records = append(records, TapeRecord{Words: words})
+        case ErrFileMark:
+            records = append(records, TapeRecord{Mark: true})
+        case io.EOF:
+            return records, nil
+        default:
+            return nil, err
+        }
+    }
+}
+
+// WriteTapeImage writes the records to w as a tape image.
+func WriteTapeImage(w io.Writer, records []TapeRecord) error {
+    for _, rec := range records {
+        var err error
+        if rec.Mark {
+            err = binary.Write(w, binary.LittleEndian, uint32(tapeMark))
+        } else {
+            err = writeTapeRecord(w, rec.Words)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return binary.Write(w, binary.LittleEndian, uint32(tapeEOM))
+}
+
+// readTapeRecord reads the next record. ErrFileMark is returned for a file
+// mark and io.EOF at the end of medium.
+func readTapeRecord(r io.Reader) ([]uint16, error) {
+    var count uint32
+    if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+        if err == io.ErrUnexpectedEOF {
+            err = errors.New("truncated tape image")
+        }
+        return nil, err
+    }
+    switch count {
+    case tapeMark:
+        return nil, ErrFileMark
+    case tapeEOM:
+        return nil, io.EOF
+    }
+
+    b := make([]byte, (count + 1)&^1 + 4)
+    if _, err := io.ReadFull(r, b); err != nil {
+        return nil, errors.New("truncated tape image")
+    }
+    if binary.LittleEndian.Uint32(b[len(b) - 4:]) != count {
+        return nil, errors.New("invalid tape image")
+    }
+    words := make([]uint16, (count + 1)/2)
+    for i := range words {
+        words[i] = uint16(b[2*i]) << 8 | uint16(b[2*i + 1])
+    }
+    return words, nil
+}
+
+// writeTapeRecord writes words as a block.
+func writeTapeRecord(w io.Writer, words []uint16) error {
+    count := uint32(2*len(words))
+    b := make([]byte, count + 8)
+    binary.LittleEndian.PutUint32(b, count)
+    for i, word := range words {
+        b[4 + 2*i] = byte(word >> 8)
+        b[4 + 2*i + 1] = byte(word)
+    }
+    binary.LittleEndian.PutUint32(b[count + 4:], count)
+    _, err := w.Write(b)
+    return err
+}
+
+// TapeImage is a tape image that is positioned like a tape drive. The image is
+// write locked if the underlying media does not implement io.Writer or if it
+// has been locked by SetWriteLocked.
+type TapeImage struct {
+    rs io.ReadSeeker
+    locked bool     // Write locked
+    pos int64       // Offset of next record
+}
+
+// NewTapeImage returns a tape image read from rs, positioned at the load
+// point.
+func NewTapeImage(rs io.ReadSeeker) *TapeImage {
+    _, ok := rs.(io.Writer)
+    return &TapeImage{rs: rs, locked: !ok}
+}
+
+// SetWriteLocked write locks the tape or, if the underlying media implements
+// io.Writer, unlocks it. It must be called before the tape is attached.
+func (t *TapeImage) SetWriteLocked(on bool) {
+    _, ok := t.rs.(io.Writer)
+    t.locked = on || !ok
+}
+
+// WriteLocked indicates whether the tape cannot be written.
+func (t *TapeImage) WriteLocked() bool {
+    return t.locked
+}
+
+// AtLoadPoint indicates whether the tape is positioned at the load point.
+func (t *TapeImage) AtLoadPoint() bool {
+    return t.pos == 0
+}
+
+// Rewind positions the tape at the load point.
+func (t *TapeImage) Rewind() {
+    t.pos = 0
+}
+
+// ReadBlock reads the next block. ErrFileMark is returned if a file mark is
+// read and io.EOF is returned at the end of the recorded tape; in both cases
+// the tape is positioned after the mark.
+func (t *TapeImage) ReadBlock() ([]uint16, error) {
+    if _, err := t.rs.Seek(t.pos, io.SeekStart); err != nil {
+        return nil, err
+    }
+    words, err := readTapeRecord(t.rs)
+    switch err {
+    case nil:
+        t.pos += int64(2*len(words) + 8)
+    case ErrFileMark:
+        t.pos += 4
+    }
+    return words, err
+}
+
+// SpaceReverse positions the tape before the previous block or file mark.
+// ErrFileMark is returned if the tape was moved over a file mark and io.EOF is
+// returned if the tape is at the load point.
+func (t *TapeImage) SpaceReverse() error {
+    if t.pos == 0 {
+        return io.EOF
+    }
+    if _, err := t.rs.Seek(t.pos - 4, io.SeekStart); err != nil {
+        return err
+    }
+    var count uint32
+    if err := binary.Read(t.rs, binary.LittleEndian, &count); err != nil {
+        return err
+    }
+    if count == tapeMark {
+        t.pos -= 4
+        return ErrFileMark
+    }
+    t.pos -= int64((count + 1)&^1 + 8)
+    if t.pos < 0 {
+        t.pos = 0
+        return errors.New("invalid tape image")
+    }
+    return nil
+}
+
+// WriteBlock writes words as a block at the current position. Anything
+// previously recorded after the block is lost.
+func (t *TapeImage) WriteBlock(words []uint16) error {
+    return t.write(func(w io.Writer) error {
+        return writeTapeRecord(w, words)
+    }, int64(2*len(words) + 8))
+}
+
+// WriteFileMark writes a file mark at the current position. Anything
+// previously recorded after the mark is lost.
+func (t *TapeImage) WriteFileMark() error {
+    return t.write(func(w io.Writer) error {
+        return binary.Write(w, binary.LittleEndian, uint32(tapeMark))
+    }, 4)
+}
+
+// write records a block or mark of size bytes followed by the end of medium.
+func (t *TapeImage) write(record func(w io.Writer) error, size int64) error {
+    if t.locked {
+        return ErrWriteLocked
+    }
+    w := t.rs.(io.Writer)
+    if _, err := t.rs.Seek(t.pos, io.SeekStart); err != nil {
+        return err
+    }
+    if err := record(w); err != nil {
+        return err
+    }
+    if err := binary.Write(w, binary.LittleEndian, uint32(tapeEOM)); err != nil {
+        return err
+    }
+    t.pos += size
+    return nil
+}
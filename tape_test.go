@@ -0,0 +1,171 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "io"
+    "os"
+    "path/filepath"
+
+    "testing"
+)
+
+func TestTapeImage(t *testing.T) {
+    records := []TapeRecord{
+        {Words: []uint16{0123456, 0177777, 0}},
+        {Mark: true},
+        {Words: []uint16{1}},
+        {Mark: true},
+    }
+    var b bytes.Buffer
+    err := WriteTapeImage(&b, records)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := 8 + 6 + 4 + 8 + 2 + 4 + 4
+    if b.Len() != want {
+        t.Errorf("size: have: %d, want: %d", b.Len(), want)
+    }
+
+    have, err := ReadTapeImage(bytes.NewReader(b.Bytes()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(have) != len(records) {
+        t.Fatalf("records: have: %d, want: %d", len(have), len(records))
+    }
+    for i, rec := range records {
+        if have[i].Mark != rec.Mark || len(have[i].Words) != len(rec.Words) {
+            t.Errorf("record %d: have: %v, want: %v", i, have[i], rec)
+            continue
+        }
+        for j := range rec.Words {
+            if have[i].Words[j] != rec.Words[j] {
+                t.Errorf("record %d: have: %v, want: %v", i, have[i], rec)
+            }
+        }
+    }
+
+    tape := NewTapeImage(bytes.NewReader(b.Bytes()))
+    if !tape.WriteLocked() {
+        t.Error("write locked: have: false, want: true")
+    }
+    if err := tape.WriteFileMark(); err != ErrWriteLocked {
+        t.Errorf("have: %v, want: %v", err, ErrWriteLocked)
+    }
+    tape.ReadBlock()
+    if _, err := tape.ReadBlock(); err != ErrFileMark {
+        t.Errorf("have: %v, want: %v", err, ErrFileMark)
+    }
+    if err := tape.SpaceReverse(); err != ErrFileMark {
+        t.Errorf("have: %v, want: %v", err, ErrFileMark)
+    }
+    if err := tape.SpaceReverse(); err != nil {
+        t.Errorf("have: %v, want: nil", err)
+    }
+    if !tape.AtLoadPoint() {
+        t.Error("load point: have: false, want: true")
+    }
+}
+
+func TestCassette(t *testing.T) {
+    f, err := os.Create(filepath.Join(t.TempDir(), "cas.tap"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    n := NewNova()
+    err = n.Attach(DevCAS, NewTapeImage(f))
+    if err != nil {
+        t.Fatal(err)
+    }
+    block := []uint16{1, 2, 3, 4}
+    n.LoadMemory(0100, block)
+
+    d := n.devices[DevCAS]
+    command := func(cmd, addr, count uint16) uint16 {
+        d.write(ioDOB, 0, addr)
+        d.write(ioDOC, 0, count)
+        d.write(ioDOA, ioS, cmd)
+        waitDone(t, d)
+        return d.read(ioDIA, ioC)
+    }
+
+    command(casWrite, 0100, uint16(len(block)))
+    command(casWriteMark, 0, 0)
+    status := command(casRewind, 0, 0)
+    if status != casBOT {
+        t.Errorf("status: have: %06o, want: %06o", status, casBOT)
+    }
+
+    // Read block into memory with a short word count
+    status = command(casRead, 0200, 2)
+    if status != casLength {
+        t.Errorf("status: have: %06o, want: %06o", status, casLength)
+    }
+    if length := d.read(ioDIC, 0); length != 4 {
+        t.Errorf("length: have: %d, want: 4", length)
+    }
+    for i, want := range []int{1, 2, 0} {
+        have, _ := n.Examine(0200 + i)
+        if have != want {
+            t.Errorf("%05o: have: %06o, want: %06o", 0200 + i, have, want)
+        }
+    }
+
+    status = command(casRead, 0200, 2)
+    if status != casFileMark {
+        t.Errorf("status: have: %06o, want: %06o", status, casFileMark)
+    }
+    status = command(casRead, 0200, 2)
+    if status != casEOT {
+        t.Errorf("status: have: %06o, want: %06o", status, casEOT)
+    }
+
+    // Image is readable as a tape
+    f.Seek(0, io.SeekStart)
+    records, err := ReadTapeImage(f)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(records) != 2 || len(records[0].Words) != 4 || !records[1].Mark {
+        t.Errorf("have: %v, want: block of 4 words and file mark", records)
+    }
+
+    // A file opened read only is write locked
+    ro, err := os.Open(f.Name())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ro.Close()
+    err = n.Attach(DevCAS, ro)
+    if err != nil {
+        t.Fatal(err)
+    }
+    status = command(casWriteMark, 0, 0)
+    if status&casWriteLock == 0 {
+        t.Errorf("status: have: %06o, want: %06o", status, casWriteLock)
+    }
+}
@@ -0,0 +1,181 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "bytes"
+)
+
+// Parity generated on characters typed at the keyboard.
+const (
+    ParityNone = iota   // Characters unchanged
+    ParityMark          // Bit 7 set
+    ParitySpace         // Bit 7 clear
+    ParityEven          // Even number of bits set
+    ParityOdd           // Odd number of bits set
+)
+
+// Line feed policies for printed characters.
+const (
+    LFPass = iota       // Characters unchanged
+    LFAfterCR           // Line feed inserted after a carriage return
+    CRBeforeLF          // Carriage return inserted before a line feed
+)
+
+// Teletype is a profile that makes a modern terminal behave like a Teletype.
+// Parity is generated on characters typed and stripped from characters
+// printed, except with ParityNone. If UpperCase is set, lower case letters
+// are typed and printed as upper case. LineFeed selects how line ends are
+// printed and, if Columns is non-zero, characters printed beyond the last
+// column of the carriage overprint the last column. NUL and rubout do not
+// print.
+type Teletype struct {
+    Parity int
+    UpperCase bool
+    LineFeed int
+    Columns int
+}
+
+// ASR33 is the profile of the ASR-33 Teletype.
+var ASR33 = Teletype{
+    Parity: ParityMark,
+    UpperCase: true,
+    LineFeed: LFPass,
+    Columns: 72,
+}
+
+// Reader returns a reader that applies the profile to the keyboard input r.
+func (t Teletype) Reader(r io.Reader) io.Reader {
+    return &ttyReader{t, r}
+}
+
+// Writer returns a writer that applies the profile to the printer output w.
+func (t Teletype) Writer(w io.Writer) io.Writer {
+    return &ttyWriter{t: t, w: w}
+}
+
+// ReadWriter returns the profile applied to rw for use on a multiplexer line.
+func (t Teletype) ReadWriter(rw io.ReadWriter) io.ReadWriter {
+    return struct{
+        io.Reader
+        io.Writer
+    }{t.Reader(rw), t.Writer(rw)}
+}
+
+// ttyReader applies a Teletype profile to keyboard input.
+type ttyReader struct {
+    t Teletype
+    r io.Reader
+}
+
+func (r *ttyReader) Read(p []byte) (int, error) {
+    n, err := r.r.Read(p)
+    for i, c := range p[:n] {
+        if r.t.Parity != ParityNone {
+            c &= 0177
+        }
+        if r.t.UpperCase && c >= 'a' && c <= 'z' {
+            c -= 'a' - 'A'
+        }
+        p[i] = parity(c, r.t.Parity)
+    }
+    return n, err
+}
+
+// parity returns the 7-bit character c with the parity bit set for mode.
+func parity(c byte, mode int) byte {
+    odd := false
+    for b := c; b != 0; b >>= 1 {
+        odd = odd != (b&1 != 0)
+    }
+    switch mode {
+    case ParityMark:
+        c |= 0200
+    case ParityEven:
+        if odd {
+            c |= 0200
+        }
+    case ParityOdd:
+        if !odd {
+            c |= 0200
+        }
+    }
+    return c
+}
+
+// ttyWriter applies a Teletype profile to printer output.
+type ttyWriter struct {
+    t Teletype
+    w io.Writer
+    col int         // Carriage position
+    cr bool         // Last character was carriage return
+}
+
+func (w *ttyWriter) Write(p []byte) (int, error) {
+    var b bytes.Buffer
+    for _, c := range p {
+        if w.t.Parity != ParityNone {
+            c &= 0177
+        }
+        lf := w.cr && c == '\n'
+        w.cr = c == '\r'
+        switch {
+        case c == 0 || c == 0177:
+            // Not printed
+        case c == '\r':
+            b.WriteByte(c)
+            if w.t.LineFeed == LFAfterCR {
+                b.WriteByte('\n')
+            }
+            w.col = 0
+        case c == '\n':
+            if w.t.LineFeed == LFAfterCR && lf {
+                // Already inserted
+                break
+            }
+            if w.t.LineFeed == CRBeforeLF && !lf {
+                b.WriteByte('\r')
+                w.col = 0
+            }
+            b.WriteByte(c)
+        case c < ' ':
+            b.WriteByte(c)
+        default:
+            if w.t.UpperCase && c >= 'a' && c <= 'z' {
+                c -= 'a' - 'A'
+            }
+            if w.t.Columns > 0 && w.col >= w.t.Columns {
+                // Carriage at right margin; overprint last column
+                b.WriteByte('\b')
+            } else {
+                w.col++
+            }
+            b.WriteByte(c)
+        }
+    }
+    if _, err := w.w.Write(b.Bytes()); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
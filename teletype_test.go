@@ -0,0 +1,96 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "bytes"
+    "io/ioutil"
+    "strings"
+
+    "testing"
+)
+
+func TestTeletypeReader(t *testing.T) {
+    tests := []struct{
+        parity int
+        in string
+        want string
+    }{
+        {ParityMark, "ab\r", "\301\302\215"},
+        {ParitySpace, "\301b", "AB"},
+        {ParityEven, "ACc", "A\303\303"},
+        {ParityOdd, "AC", "\301C"},
+        {ParityNone, "a\341", "A\341"},
+    }
+    for _, test := range tests {
+        tty := Teletype{Parity: test.parity, UpperCase: true}
+        b, err := ioutil.ReadAll(tty.Reader(strings.NewReader(test.in)))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if string(b) != test.want {
+            t.Errorf("parity %d: have: %q, want: %q", test.parity, b, test.want)
+        }
+    }
+}
+
+func TestTeletypeWriter(t *testing.T) {
+    tests := []struct{
+        lf int
+        in string
+        want string
+    }{
+        {LFPass, "\301b\000\377\r\n", "AB\r\n"},
+        {LFAfterCR, "A\r\nB\rC", "A\r\nB\r\nC"},
+        {CRBeforeLF, "A\nB\r\n", "A\r\nB\r\n"},
+    }
+    for _, test := range tests {
+        var b bytes.Buffer
+        tty := ASR33
+        tty.LineFeed = test.lf
+        w := tty.Writer(&b)
+        for _, c := range []byte(test.in) {
+            w.Write([]byte{c})
+        }
+        if b.String() != test.want {
+            t.Errorf("policy %d: have: %q, want: %q", test.lf, b.String(), test.want)
+        }
+    }
+
+    // Carriage stops at column 72
+    var b bytes.Buffer
+    ASR33.Writer(&b).Write([]byte(strings.Repeat("X", 72) + "YZ\rW"))
+    want := strings.Repeat("X", 72) + "\bY\bZ\rW"
+    if b.String() != want {
+        t.Errorf("margin: have: %q, want: %q", b.String(), want)
+    }
+
+    // Bit 7 is kept without parity
+    b.Reset()
+    tty := ASR33
+    tty.Parity = ParityNone
+    tty.Writer(&b).Write([]byte("\301b"))
+    if b.String() != "\301B" {
+        t.Errorf("no parity: have: %q, want: %q", b.String(), "\301B")
+    }
+}
@@ -0,0 +1,470 @@
+// MIT License
+// 
+// Copyright 2017 Jeremy Hall
+// 
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+// 
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+// 
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package nova
+
+import (
+    "io"
+    "net"
+    "os"
+    "sync"
+    "time"
+)
+
+// Telnet commands and options.
+const (
+    telSE   = 240
+    telSB   = 250
+    telWILL = 251
+    telWONT = 252
+    telDO   = 253
+    telDONT = 254
+    telIAC  = 255
+
+    telBinary = 0
+    telEcho   = 1
+    telSGA    = 3
+)
+
+// TelnetPort is a TCP listener that speaks enough of the telnet protocol for
+// standard clients. One client may be connected at a time; further clients are
+// refused until the current client disconnects. Read blocks until a client
+// sends data and Write discards data while no client is connected.
+//
+// If binary mode is set, the server also offers binary transmission in both
+// directions, so that 8-bit characters pass unchanged. If a session log is
+// set, the data sent to clients is copied to it, giving a transcript of the
+// sessions as seen by the clients.
+//
+// Reads are serialized, and a read deadline may be set so that a blocked
+// reader can be released when the port is detached from a device.
+//
+// TelnetPort implements the Modem interface. While DTR is off, a connecting
+// client rings the line and is not answered until DTR is turned on. Turning
+// DTR off disconnects an answered client. DTR is initially on.
+type TelnetPort struct {
+    l net.Listener
+    mu sync.Mutex
+    conn net.Conn         // Connected client
+    answered bool         // Client has been answered
+    dtr bool              // Data terminal ready
+    binary bool           // Offer binary transmission
+    log io.Writer         // Session log
+    logMu sync.Mutex      // Serializes session log writes
+    expired chan struct{} // Closed when the read deadline passes
+    changed chan struct{} // Closed when the read deadline is set
+    timer *time.Timer     // Read deadline timer
+    in chan []byte        // Data received from clients
+    rmu sync.Mutex        // Serializes reads
+    buf []byte            // Unread data
+    closed chan struct{}
+    once sync.Once
+}
+
+// ListenTelnet creates a telnet port listening on the TCP network address
+// addr.
+func ListenTelnet(addr string) (*TelnetPort, error) {
+    l, err := net.Listen("tcp", addr)
+    if err != nil {
+        return nil, err
+    }
+    p := &TelnetPort{
+        l: l,
+        dtr: true,
+        in: make(chan []byte),
+        changed: make(chan struct{}),
+        closed: make(chan struct{}),
+    }
+    go p.accept()
+    return p, nil
+}
+
+// SetBinary sets whether binary transmission is offered to clients that
+// connect after the call.
+func (p *TelnetPort) SetBinary(on bool) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.binary = on
+}
+
+// SetLog sets the session log. A nil w stops logging.
+func (p *TelnetPort) SetLog(w io.Writer) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.log = w
+}
+
+// Addr returns the listener network address.
+func (p *TelnetPort) Addr() net.Addr {
+    return p.l.Addr()
+}
+
+// Connected indicates whether a client is connected and has been answered.
+func (p *TelnetPort) Connected() bool {
+    return p.Carrier()
+}
+
+// Carrier implements the Modem interface. Carrier is present while an answered
+// client is connected.
+func (p *TelnetPort) Carrier() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.conn != nil && p.answered
+}
+
+// Ring implements the Modem interface. The line rings while a client waits to
+// be answered.
+func (p *TelnetPort) Ring() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.conn != nil && !p.answered
+}
+
+// SetDTR implements the Modem interface.
+func (p *TelnetPort) SetDTR(on bool) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.dtr = on
+    if p.conn == nil {
+        return
+    }
+    if on && !p.answered {
+        p.answered = true
+        go p.serve(p.conn)
+    } else if !on && p.answered {
+        // Hang up; serve cleans up when its read fails
+        p.conn.Close()
+    }
+}
+
+// SetReadDeadline sets the time after which a blocked or future Read fails
+// with os.ErrDeadlineExceeded. A zero t clears the deadline.
+func (p *TelnetPort) SetReadDeadline(t time.Time) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.timer != nil {
+        p.timer.Stop()
+        p.timer = nil
+    }
+    close(p.changed)
+    p.changed = make(chan struct{})
+    p.expired = nil
+    if t.IsZero() {
+        return nil
+    }
+    expired := make(chan struct{})
+    p.expired = expired
+    if d := time.Until(t); d > 0 {
+        p.timer = time.AfterFunc(d, func() { close(expired) })
+    } else {
+        close(expired)
+    }
+    return nil
+}
+
+// Read reads data sent by clients with telnet commands removed. io.EOF is
+// returned once the port is closed.
+func (p *TelnetPort) Read(b []byte) (int, error) {
+    p.rmu.Lock()
+    defer p.rmu.Unlock()
+    for {
+        p.mu.Lock()
+        expired, changed := p.expired, p.changed
+        p.mu.Unlock()
+        select {
+        case <-expired:
+            return 0, os.ErrDeadlineExceeded
+        default:
+        }
+        if len(p.buf) != 0 {
+            break
+        }
+        select {
+        case p.buf = <-p.in:
+        case <-p.closed:
+            return 0, io.EOF
+        case <-expired:
+            return 0, os.ErrDeadlineExceeded
+        case <-changed:
+        }
+    }
+    n := copy(b, p.buf)
+    p.buf = p.buf[n:]
+    return n, nil
+}
+
+// Write sends data to the connected client, if any.
+func (p *TelnetPort) Write(b []byte) (int, error) {
+    p.mu.Lock()
+    c := p.conn
+    if !p.answered {
+        c = nil
+    }
+    log := p.log
+    p.mu.Unlock()
+    if log != nil {
+        p.logMu.Lock()
+        log.Write(b)
+        p.logMu.Unlock()
+    }
+    if c == nil {
+        return len(b), nil
+    }
+
+    out := make([]byte, 0, len(b))
+    for _, ch := range b {
+        if ch == telIAC {
+            out = append(out, telIAC)
+        }
+        out = append(out, ch)
+    }
+    if _, err := c.Write(out); err != nil {
+        // Client has gone away
+        p.disconnect(c)
+    }
+    return len(b), nil
+}
+
+// Close stops listening and disconnects any client.
+func (p *TelnetPort) Close() error {
+    var err error
+    p.once.Do(func() {
+        close(p.closed)
+        err = p.l.Close()
+        p.mu.Lock()
+        if p.conn != nil {
+            p.conn.Close()
+            p.conn = nil
+        }
+        p.mu.Unlock()
+    })
+    return err
+}
+
+// accept accepts clients until the listener is closed.
+func (p *TelnetPort) accept() {
+    for {
+        c, err := p.l.Accept()
+        if err != nil {
+            return
+        }
+        p.mu.Lock()
+        busy := p.conn != nil
+        if !busy {
+            p.conn = c
+            p.answered = p.dtr
+        }
+        answered := p.answered
+        p.mu.Unlock()
+        if busy {
+            c.Write([]byte("\r\nline busy\r\n"))
+            c.Close()
+            continue
+        }
+        if answered {
+            go p.serve(c)
+        }
+    }
+}
+
+// disconnect closes the client connection c.
+func (p *TelnetPort) disconnect(c net.Conn) {
+    p.mu.Lock()
+    if p.conn == c {
+        p.conn = nil
+        p.answered = false
+    }
+    p.mu.Unlock()
+    c.Close()
+}
+
+// serve receives data from the client c until it disconnects.
+func (p *TelnetPort) serve(c net.Conn) {
+    defer p.disconnect(c)
+
+    p.mu.Lock()
+    binary := p.binary
+    p.mu.Unlock()
+    t := newTelnetConn(c)
+    t.negotiate(binary)
+    b := make([]byte, 512)
+    for {
+        n, err := c.Read(b)
+        if err != nil {
+            return
+        }
+        data := t.filter(b[:n])
+        if len(data) == 0 {
+            continue
+        }
+        select {
+        case p.in <- data:
+        case <-p.closed:
+            return
+        }
+    }
+}
+
+// Telnet receive state.
+const (
+    telData = iota
+    telCmd
+    telOpt
+    telSub
+    telSubIAC
+    telCR
+)
+
+// telnetConn holds the protocol state of a client connection.
+type telnetConn struct {
+    w io.Writer
+    state int
+    cmd byte        // Option negotiation command
+    us [256]bool    // Options enabled locally
+    him [256]bool   // Options enabled by client
+}
+
+func newTelnetConn(w io.Writer) *telnetConn {
+    return &telnetConn{w: w}
+}
+
+// negotiate offers the options needed for character at a time operation: the
+// server echoes input and go-aheads are suppressed in both directions. If
+// binary is set, binary transmission is offered in both directions.
+func (t *telnetConn) negotiate(binary bool) {
+    t.us[telEcho] = true
+    t.us[telSGA] = true
+    t.him[telSGA] = true
+    cmds := []byte{
+        telIAC, telWILL, telEcho,
+        telIAC, telWILL, telSGA,
+        telIAC, telDO, telSGA,
+    }
+    if binary {
+        t.us[telBinary] = true
+        t.him[telBinary] = true
+        cmds = append(cmds,
+            telIAC, telWILL, telBinary,
+            telIAC, telDO, telBinary)
+    }
+    t.w.Write(cmds)
+}
+
+// supported indicates whether an option may be enabled.
+func (t *telnetConn) supported(opt byte) bool {
+    switch opt {
+    case telBinary, telEcho, telSGA:
+        return true
+    }
+    return false
+}
+
+// option responds to a client option negotiation command.
+func (t *telnetConn) option(cmd, opt byte) {
+    var reply byte
+    switch cmd {
+    case telDO:
+        if t.us[opt] {
+            return
+        }
+        if opt != telEcho && t.supported(opt) {
+            t.us[opt] = true
+            reply = telWILL
+        } else {
+            reply = telWONT
+        }
+    case telDONT:
+        if !t.us[opt] {
+            return
+        }
+        t.us[opt] = false
+        reply = telWONT
+    case telWILL:
+        if t.him[opt] {
+            return
+        }
+        if opt != telEcho && t.supported(opt) {
+            t.him[opt] = true
+            reply = telDO
+        } else {
+            reply = telDONT
+        }
+    case telWONT:
+        if !t.him[opt] {
+            return
+        }
+        t.him[opt] = false
+        reply = telDONT
+    }
+    t.w.Write([]byte{telIAC, reply, opt})
+}
+
+// filter removes telnet commands from the received data b. Unless the client
+// is sending in binary mode, CR LF and CR NUL are received as CR.
+func (t *telnetConn) filter(b []byte) []byte {
+    var data []byte
+    for _, c := range b {
+        switch t.state {
+        case telData, telCR:
+            cr := t.state == telCR
+            t.state = telData
+            if c == telIAC {
+                t.state = telCmd
+                continue
+            }
+            if cr && (c == '\n' || c == 0) {
+                continue
+            }
+            if c == '\r' && !t.him[telBinary] {
+                t.state = telCR
+            }
+            data = append(data, c)
+        case telCmd:
+            switch c {
+            case telIAC:
+                data = append(data, c)
+                t.state = telData
+            case telWILL, telWONT, telDO, telDONT:
+                t.cmd = c
+                t.state = telOpt
+            case telSB:
+                t.state = telSub
+            default:
+                t.state = telData
+            }
+        case telOpt:
+            t.option(t.cmd, c)
+            t.state = telData
+        case telSub:
+            if c == telIAC {
+                t.state = telSubIAC
+            }
+        case telSubIAC:
+            if c == telSE {
+                t.state = telData
+            } else {
+                t.state = telSub
+            }
+        }
+    }
+    return data
+}